@@ -91,7 +91,7 @@ func distributor(p golParams, d distributorChans, alive chan []cell) {
 
 	for y := 0; y < p.imageHeight; y++ {
 		for x := 0; x < p.imageWidth; x++ {
-			d.io.outputVal <- world[x][y]
+			d.io.outputVal <- world[y][x]
 		}
 	}
 
@@ -0,0 +1,141 @@
+package reference
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Random returns a world of the given size in which each cell is alive with the given probability.
+func Random(r *rand.Rand, width, height int, density float64) [][]byte {
+	world := Empty(width, height)
+	for y := range world {
+		for x := range world[y] {
+			if r.Float64() < density {
+				world[y][x] = 0xFF
+			}
+		}
+	}
+	return world
+}
+
+// WritePGM saves the world as a binary pgm, the format the stages read their images in.
+func WritePGM(path string, world [][]byte) error {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "P5\n%d %d\n255\n", Width(world), len(world))
+	for _, row := range world {
+		buffer.Write(row)
+	}
+	return ioutil.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+// ReadPGM loads a binary pgm written by one of the stages.
+func ReadPGM(path string) ([][]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// The header is four fields, and the pixels start after the single whitespace character that follows the last.
+	var fields []string
+	header := 0
+	for len(fields) < 4 {
+		for header < len(data) && isSpace(data[header]) {
+			header++
+		}
+		start := header
+		for header < len(data) && !isSpace(data[header]) {
+			header++
+		}
+		if start == header {
+			break
+		}
+		fields = append(fields, string(data[start:header]))
+	}
+	var width, height int
+	if len(fields) == 4 {
+		width, _ = strconv.Atoi(fields[1])
+		height, _ = strconv.Atoi(fields[2])
+	}
+	if len(fields) != 4 || fields[0] != "P5" || fields[3] != "255" || width <= 0 || height <= 0 {
+		return nil, errors.New(path + " is not a pgm written by the Game of Life")
+	}
+	pixels := data[min(header+1, len(data)):]
+	if len(pixels) != width*height {
+		return nil, fmt.Errorf("%s holds %d pixels, not %dx%d", path, len(pixels), width, height)
+	}
+
+	world := Empty(width, height)
+	for y := range world {
+		copy(world[y], pixels[y*width:])
+	}
+	return world, nil
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// Draw draws the world as text, with '#' for an alive cell and '.' for a dead one.
+func Draw(world [][]byte) string {
+	var drawing strings.Builder
+	for _, row := range world {
+		for _, c := range row {
+			if c != 0 {
+				drawing.WriteByte('#')
+			} else {
+				drawing.WriteByte('.')
+			}
+		}
+		drawing.WriteByte('\n')
+	}
+	return drawing.String()
+}
+
+// DrawDiff draws two worlds of the same size on top of each other, with '#' for a cell alive in both,
+// 'w' for a cell alive only in the one that was wanted and 'g' for a cell alive only in the one that was got.
+func DrawDiff(want, got [][]byte) string {
+	var drawing strings.Builder
+	for y := range want {
+		for x := range want[y] {
+			wanted, gotten := want[y][x] != 0, got[y][x] != 0
+			switch {
+			case wanted && gotten:
+				drawing.WriteByte('#')
+			case wanted:
+				drawing.WriteByte('w')
+			case gotten:
+				drawing.WriteByte('g')
+			default:
+				drawing.WriteByte('.')
+			}
+		}
+		drawing.WriteByte('\n')
+	}
+	return drawing.String()
+}
+
+// Same reports whether two worlds are the same size and have the same cells alive.
+func Same(a, b [][]byte) bool {
+	if len(a) != len(b) || Width(a) != Width(b) {
+		return false
+	}
+	for y := range a {
+		for x := range a[y] {
+			if (a[y][x] != 0) != (b[y][x] != 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
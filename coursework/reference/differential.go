@@ -0,0 +1,254 @@
+package reference
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Flags for the differential test, shared by every stage. For example,
+//
+//	go test -run TestDifferential -differential.cases 500 -differential.seed 0
+//
+// checks 500 boards picked with a new seed.
+var (
+	cases   = flag.Int("differential.cases", 20, "Specify how many random boards to check the engine on.")
+	seed    = flag.Int64("differential.seed", 1, "Specify the seed for the random boards, or 0 to pick a new one.")
+	timeout = flag.Duration("differential.timeout", 10*time.Second, "Specify how long the engine may take on one board before it counts as too slow.")
+)
+
+// maxShrinks is how many smaller cases shrinking tries before it settles for the smallest failing one so far.
+const maxShrinks = 2000
+
+// Case is one run of an engine: a starting world, and how many threads and turns to run it for.
+type Case struct {
+	World   [][]byte
+	Threads int
+	Turns   int
+}
+
+func (c Case) String() string {
+	return fmt.Sprintf("%dx%d with %d alive, %d threads, %d turns", Width(c.World), len(c.World), Alive(c.World), c.Threads, c.Turns)
+}
+
+// Engine runs one of the stages on the case and returns the world it finished with.
+// It is called in a directory holding the case's world as images/WxH.pgm, the same as the stages' own tests.
+type Engine func(c Case) [][]byte
+
+// Limits describes the cases an engine can be given.
+type Limits struct {
+	// MinSize and MaxSize bound the width and height of the world.
+	MinSize, MaxSize int
+
+	// MaxThreads and MaxTurns bound the number of threads and turns.
+	MaxThreads, MaxTurns int
+
+	// Supports reports whether the engine can run a case, for engines that cannot split
+	// every world between every number of threads. Nil means every case within the bounds.
+	Supports func(c Case) bool
+}
+
+func (l Limits) allows(c Case) bool {
+	width, height := Width(c.World), len(c.World)
+	return width >= l.MinSize && width <= l.MaxSize && height >= l.MinSize && height <= l.MaxSize &&
+		c.Threads >= 1 && c.Threads <= l.MaxThreads && c.Turns >= 0 && c.Turns <= l.MaxTurns &&
+		(l.Supports == nil || l.Supports(c))
+}
+
+// random picks a case the engine supports, of any size, density, number of threads and number of turns.
+func (l Limits) random(r *rand.Rand) Case {
+	for {
+		density := []float64{0.05, 0.2, 0.35, 0.5, 0.8}[r.Intn(5)]
+		c := Case{
+			World:   Random(r, l.MinSize+r.Intn(l.MaxSize-l.MinSize+1), l.MinSize+r.Intn(l.MaxSize-l.MinSize+1), density),
+			Threads: 1 + r.Intn(l.MaxThreads),
+			Turns:   r.Intn(l.MaxTurns + 1),
+		}
+		if l.allows(c) {
+			return c
+		}
+	}
+}
+
+// Differential runs the engine and the reference on random cases and fails the test if they ever disagree,
+// about the cells the engine returns or about the image it writes to out/.
+// The first case that fails is shrunk to the smallest case that still fails before it is reported.
+func Differential(t *testing.T, limits Limits, engine Engine) {
+	defer InTempDir(t)()
+
+	s := *seed
+	if s == 0 {
+		s = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(s))
+	for i := 0; i < *cases; i++ {
+		c := limits.random(r)
+		if check(engine, c) == "" {
+			continue
+		}
+		shrunk := Shrink(c, limits, func(c Case) bool { return check(engine, c) != "" })
+		t.Errorf("Seed %d, case %d: %v\nShrunk to %v, starting from\n%s%s",
+			s, i, c, shrunk, Draw(shrunk.World), check(engine, shrunk))
+		return
+	}
+}
+
+// InTempDir moves into a new directory with an empty images/ in it, for the files an engine reads and writes,
+// so that tests leave nothing behind in the source tree.
+// It returns a function that moves back.
+// It changes the working directory of the whole process, so it must not be used by tests that call t.Parallel.
+func InTempDir(tb testing.TB) func() {
+	dir := tb.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.Mkdir("images", os.ModePerm); err != nil {
+		os.Chdir(wd)
+		tb.Fatal(err)
+	}
+	return func() { os.Chdir(wd) }
+}
+
+// check runs the engine on the case and describes how it went wrong, or returns "" if it got the right answer.
+// An engine that takes longer than the timeout fails, but check still waits for it to finish,
+// so that it never runs on into the next case. One that never finishes is left for go test's own -timeout to stop,
+// which prints where every goroutine is stuck.
+func check(engine Engine, c Case) string {
+	width, height := Width(c.World), len(c.World)
+	name := strconv.Itoa(width) + "x" + strconv.Itoa(height)
+	if err := WritePGM(filepath.Join("images", name+".pgm"), c.World); err != nil {
+		return err.Error()
+	}
+	_ = os.RemoveAll("out")
+
+	done := make(chan [][]byte, 1)
+	go func() {
+		done <- engine(c)
+	}()
+	timer := time.NewTimer(*timeout)
+	defer timer.Stop()
+	select {
+	case got := <-done:
+		return compare(c, got)
+	case <-timer.C:
+		fmt.Fprintf(os.Stderr, "The engine has not finished %v within %v, waiting for it.\n", c, *timeout)
+		<-done
+		return fmt.Sprintf("The engine did not finish within %v.\n", *timeout)
+	}
+}
+
+// compare describes how the cells the engine finished with and the image it wrote differ from the reference,
+// or returns "" if neither does.
+func compare(c Case, got [][]byte) string {
+	width, height := Width(c.World), len(c.World)
+	name := strconv.Itoa(width) + "x" + strconv.Itoa(height)
+
+	want := Run(c.World, c.Turns)
+	if !Same(want, got) {
+		return "The engine finished with the wrong cells ('w' wanted, 'g' got):\n" + DrawDiff(want, got)
+	}
+	image, err := ReadPGM(filepath.Join("out", name+"_"+strconv.Itoa(c.Turns)+".pgm"))
+	if err != nil {
+		return err.Error() + "\n"
+	}
+	if !Same(want, image) {
+		if len(image) != height || Width(image) != width {
+			return fmt.Sprintf("The engine wrote a %dx%d image.\n", Width(image), len(image))
+		}
+		return "The engine wrote the wrong image ('w' wanted, 'g' got):\n" + DrawDiff(want, image)
+	}
+	return ""
+}
+
+// Shrink looks for a smaller case that still fails, with fewer turns, fewer threads, fewer rows and columns
+// or fewer alive cells, and keeps going until none of those fail any more.
+func Shrink(c Case, limits Limits, fails func(c Case) bool) Case {
+	for tries := 0; tries < maxShrinks; {
+		shrunk := false
+		for _, smaller := range smallerCases(c) {
+			if !limits.allows(smaller) {
+				continue
+			}
+			tries++
+			if fails(smaller) {
+				c, shrunk = smaller, true
+				break
+			}
+			if tries == maxShrinks {
+				break
+			}
+		}
+		if !shrunk {
+			break
+		}
+	}
+	return c
+}
+
+// smallerCases lists the cases one step smaller than c, the biggest steps first.
+func smallerCases(c Case) []Case {
+	var smaller []Case
+	for _, turns := range []int{0, c.Turns / 2, c.Turns - 1} {
+		if turns < c.Turns && (len(smaller) == 0 || smaller[len(smaller)-1].Turns != turns) {
+			smaller = append(smaller, Case{World: c.World, Threads: c.Threads, Turns: turns})
+		}
+	}
+	for threads := 1; threads < c.Threads; threads++ {
+		smaller = append(smaller, Case{World: c.World, Threads: threads, Turns: c.Turns})
+	}
+
+	// Take out runs of rows or columns, from half of them down to one at a time.
+	width, height := Width(c.World), len(c.World)
+	for size := height / 2; size >= 1; size /= 2 {
+		for start := 0; start+size <= height; start += size {
+			world := append(append([][]byte(nil), c.World[:start]...), c.World[start+size:]...)
+			smaller = append(smaller, Case{World: Copy(world), Threads: c.Threads, Turns: c.Turns})
+		}
+	}
+	for size := width / 2; size >= 1; size /= 2 {
+		for start := 0; start+size <= width; start += size {
+			world := make([][]byte, height)
+			for y := range world {
+				world[y] = append(append([]byte(nil), c.World[y][:start]...), c.World[y][start+size:]...)
+			}
+			smaller = append(smaller, Case{World: world, Threads: c.Threads, Turns: c.Turns})
+		}
+	}
+	// Some engines only take square worlds, so also take rows and columns off the bottom right together.
+	for size := min(width, height) / 2; size >= 1; size /= 2 {
+		world := make([][]byte, height-size)
+		for y := range world {
+			world[y] = append([]byte(nil), c.World[y][:width-size]...)
+		}
+		smaller = append(smaller, Case{World: world, Threads: c.Threads, Turns: c.Turns})
+	}
+
+	// Kill runs of alive cells, again from half of them down to one at a time.
+	var alive [][2]int
+	for y := range c.World {
+		for x := range c.World[y] {
+			if c.World[y][x] != 0 {
+				alive = append(alive, [2]int{x, y})
+			}
+		}
+	}
+	for size := len(alive) / 2; size >= 1; size /= 2 {
+		for start := 0; start < len(alive); start += size {
+			world := Copy(c.World)
+			for _, a := range alive[start:min(start+size, len(alive))] {
+				world[a[1]][a[0]] = 0
+			}
+			smaller = append(smaller, Case{World: world, Threads: c.Threads, Turns: c.Turns})
+		}
+	}
+	return smaller
+}
@@ -0,0 +1,24 @@
+#!/usr/bin/env bash
+
+# Runs the differential test of every stage against the shared reference, with the same boards for each.
+# Usage: ./differential.sh [cases] [seed]
+# A seed of 0 picks a new one, which each stage prints if it finds a failing board.
+
+cases=${1:-100}
+seed=${2:-1}
+
+cd "$(dirname "$0")/.." || exit 1
+
+failed=0
+for stage in "Stage 1A" "Stage 1B" stage2 stage3 stage4 stage5
+do
+    echo "${stage}..."
+    if ! (cd "${stage}" && go test -run TestDifferential -differential.cases "${cases}" -differential.seed "${seed}" > differential.txt)
+    then
+        grep -v "Alive cell at\|input done!\|output done!" "${stage}/differential.txt"
+        failed=1
+    fi
+    rm -f "${stage}/differential.txt"
+done
+
+exit ${failed}
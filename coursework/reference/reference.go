@@ -0,0 +1,90 @@
+// Package reference is a plain, single threaded Game of Life that the stages are checked against.
+// A world is a slice of rows, with 0xFF for an alive cell and 0 for a dead one, as in the stages.
+package reference
+
+// Empty returns a world of the given size with every cell dead.
+func Empty(width, height int) [][]byte {
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+	}
+	return world
+}
+
+// Copy returns a copy of the world that shares nothing with it.
+func Copy(world [][]byte) [][]byte {
+	copied := make([][]byte, len(world))
+	for y := range world {
+		copied[y] = append([]byte(nil), world[y]...)
+	}
+	return copied
+}
+
+// Width returns the width of the world.
+func Width(world [][]byte) int {
+	if len(world) == 0 {
+		return 0
+	}
+	return len(world[0])
+}
+
+// Step calculates the next turn on a torus, where the edges of the world wrap around.
+// On a world less than three cells wide or high a cell can be its own neighbour, or the same
+// neighbour twice, and it is counted each time. The stages do not all agree about this, so they are
+// only checked on worlds at least three cells wide and high.
+func Step(world [][]byte) [][]byte {
+	return step(world, false)
+}
+
+// StepBounded calculates the next turn in a bounded universe, where every cell past the edges is dead.
+func StepBounded(world [][]byte) [][]byte {
+	return step(world, true)
+}
+
+func step(world [][]byte, bounded bool) [][]byte {
+	width, height := Width(world), len(world)
+	next := Empty(width, height)
+	for y := range next {
+		for x := range next[y] {
+			alive := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if dx == 0 && dy == 0 {
+						continue
+					}
+					if bounded && (x+dx < 0 || x+dx >= width || y+dy < 0 || y+dy >= height) {
+						continue
+					}
+					if world[(y+dy+height)%height][(x+dx+width)%width] != 0 {
+						alive++
+					}
+				}
+			}
+			if alive == 3 || (alive == 2 && world[y][x] != 0) {
+				next[y][x] = 0xFF
+			}
+		}
+	}
+	return next
+}
+
+// Run calculates the world after the given number of turns on a torus.
+func Run(world [][]byte, turns int) [][]byte {
+	for turn := 0; turn < turns; turn++ {
+		world = Step(world)
+	}
+	return world
+}
+
+// Alive counts the alive cells in the world.
+func Alive(world [][]byte) int {
+	count := 0
+	for _, row := range world {
+		for _, c := range row {
+			if c != 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,95 @@
+package reference
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parse reads a world drawn with '#' for alive cells, one row per string.
+func parse(rows ...string) [][]byte {
+	world := Empty(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				world[y][x] = 0xFF
+			}
+		}
+	}
+	return world
+}
+
+func TestStep(t *testing.T) {
+	blinker := parse(".....", "..#..", "..#..", "..#..", ".....")
+	assert.Equal(t, parse(".....", ".....", ".###.", ".....", "....."), Step(blinker))
+	assert.Equal(t, blinker, Run(blinker, 2))
+
+	// After four turns a glider has moved one cell down and right, and it wraps around the edges.
+	glider := parse(".#...", "..#..", "###..", ".....", ".....")
+	assert.Equal(t, parse(".....", "..#..", "...#.", ".###.", "....."), Run(glider, 4))
+	assert.Equal(t, parse("#..##", ".....", ".....", "....#", "#...."), Run(glider, 12))
+
+	// The same glider dies against the edge of a bounded universe.
+	world := glider
+	for turn := 0; turn < 20; turn++ {
+		world = StepBounded(world)
+	}
+	assert.Equal(t, 4, Alive(world), "it ends as a block")
+
+	// On a world one cell high, each cell is its own neighbour above and below.
+	assert.Equal(t, parse("###"), Step(parse("#..")))
+}
+
+func TestPGM(t *testing.T) {
+	world := Random(rand.New(rand.NewSource(1)), 13, 7, 0.4)
+	path := filepath.Join(t.TempDir(), "13x7.pgm")
+	assert.NoError(t, WritePGM(path, world))
+	read, err := ReadPGM(path)
+	assert.NoError(t, err)
+	assert.Equal(t, world, read)
+
+	_, err = ReadPGM(filepath.Join(t.TempDir(), "missing.pgm"))
+	assert.Error(t, err)
+}
+
+func TestShrink(t *testing.T) {
+	limits := Limits{MinSize: 2, MaxSize: 64, MaxThreads: 8, MaxTurns: 100}
+	c := Case{World: Random(rand.New(rand.NewSource(2)), 40, 30, 0.5), Threads: 6, Turns: 80}
+
+	// A made up bug that shows once there are two alive cells in the same row and the engine runs for three turns.
+	fails := func(c Case) bool {
+		if c.Turns < 3 {
+			return false
+		}
+		for _, row := range c.World {
+			alive := 0
+			for _, cell := range row {
+				if cell != 0 {
+					alive++
+				}
+			}
+			if alive >= 2 {
+				return true
+			}
+		}
+		return false
+	}
+	shrunk := Shrink(c, limits, fails)
+	assert.Equal(t, 3, shrunk.Turns)
+	assert.Equal(t, 1, shrunk.Threads)
+	assert.Equal(t, 2, Alive(shrunk.World))
+	assert.Equal(t, 2, Width(shrunk.World))
+	assert.Equal(t, 2, len(shrunk.World))
+
+	// Shrinking stays within the limits.
+	limits.Supports = func(c Case) bool { return c.Threads%2 == 0 }
+	assert.Equal(t, 2, Shrink(c, limits, fails).Threads)
+}
+
+func TestDrawDiff(t *testing.T) {
+	assert.Equal(t, "#w\ng.\n", DrawDiff(parse("##", ".."), parse("#.", "#.")))
+	assert.True(t, Same(parse("#."), [][]byte{{1, 0}}))
+	assert.False(t, Same(parse("#."), parse("#.", "..")))
+}
@@ -0,0 +1,62 @@
+package reference
+
+import (
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Flags for the sweep benchmark, which the sweep command sets for each run. For example,
+//
+//	go test -run XXX -bench Sweep -benchmem -sweep.size 256 -sweep.threads 4
+//
+// times 100 turns of a random 256x256 world on 4 threads.
+var (
+	sweepSize    = flag.Int("sweep.size", 512, "Specify the width and height of the world to benchmark.")
+	sweepThreads = flag.Int("sweep.threads", 1, "Specify the number of threads to benchmark.")
+	sweepTurns   = flag.Int("sweep.turns", 100, "Specify the number of turns in each run of the benchmark.")
+)
+
+// sweepDensity is the share of cells alive at the start, and sweepSeed picks them, the same for every engine.
+const (
+	sweepDensity = 0.35
+	sweepSeed    = 1
+)
+
+// Sweep benchmarks the engine on the case given by the sweep flags, and reports the time per turn as ns/turn.
+// Engines that cannot split the world between that many threads skip it, as described by supports,
+// which may be nil if they can all be run.
+func Sweep(b *testing.B, supports func(c Case) bool, engine Engine) {
+	c := Case{
+		World:   Random(rand.New(rand.NewSource(sweepSeed)), *sweepSize, *sweepSize, sweepDensity),
+		Threads: *sweepThreads,
+		Turns:   *sweepTurns,
+	}
+	if *sweepSize < 1 || c.Threads < 1 || c.Turns < 1 {
+		b.Fatalf("Cannot benchmark %v", c)
+	}
+	if supports != nil && !supports(c) {
+		b.Skipf("The engine cannot run %v", c)
+	}
+
+	defer InTempDir(b)()
+	name := strconv.Itoa(*sweepSize) + "x" + strconv.Itoa(*sweepSize)
+	if err := WritePGM(filepath.Join("images", name+".pgm"), c.World); err != nil {
+		b.Fatal(err)
+	}
+
+	// The engines print as they go, which would get mixed up with the results.
+	stdout := os.Stdout
+	os.Stdout = nil
+	defer func() { os.Stdout = stdout }()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine(c)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*c.Turns), "ns/turn")
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/reference"
+)
+
+// TestDifferential checks the engine against the shared reference on random boards.
+// See the reference package for the flags that control it.
+func TestDifferential(t *testing.T) {
+	limits := reference.Limits{MinSize: 3, MaxSize: 64, MaxThreads: 8, MaxTurns: 50}
+	reference.Differential(t, limits, engine)
+}
+
+// engine runs a case for the reference package, and returns the world it finished with.
+func engine(c reference.Case) [][]byte {
+	p := golParams{
+		turns:       c.Turns,
+		threads:     c.Threads,
+		imageWidth:  reference.Width(c.World),
+		imageHeight: len(c.World),
+	}
+	world := reference.Empty(p.imageWidth, p.imageHeight)
+	for _, alive := range gameOfLife(p, nil) {
+		world[alive.y][alive.x] = 0xFF
+	}
+	return world
+}
@@ -1,9 +1,17 @@
 module uk.ac.bris.cs/gameoflife
 
-go 1.12
+go 1.20
 
 require (
-	github.com/mattn/go-runewidth v0.0.4 // indirect
 	github.com/nsf/termbox-go v0.0.0-20190325093121-288510b9734e
 	github.com/stretchr/testify v1.3.0
+	uk.ac.bris.cs/gameoflife/reference v0.0.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/mattn/go-runewidth v0.0.4 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
+
+replace uk.ac.bris.cs/gameoflife/reference => ../reference
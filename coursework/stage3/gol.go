@@ -7,6 +7,9 @@ import (
 	"time"
 )
 
+// defaultAliveInterval is how often the number of alive cells is reported, unless golParams says otherwise.
+const defaultAliveInterval = 2 * time.Second
+
 // distributor divides the work between workers and interacts with other goroutines.
 func distributor(p golParams, d distributorChans, alive chan []cell) {
 
@@ -33,22 +36,9 @@ func distributor(p golParams, d distributorChans, alive chan []cell) {
 	}
 
 	//2b - Print alive cells every 2 seconds
-	ticker := time.NewTicker(2 * time.Second)
-	alivechannel := make(chan int, 1)
-	var pause = false
-
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				alivechannel <- alivecells
-				printalive := <-alivechannel
-				if pause == false {
-					fmt.Printf("%v\n", printalive)
-				}
-			}
-		}
-	}()
+	// The ticker is read by the turn loop below, so the count always belongs to a whole turn.
+	ticker := time.NewTicker(p.aliveInterval)
+	defer ticker.Stop()
 
 	// Calculate the new state of Game of Life after the given number of turns.
 
@@ -57,7 +47,7 @@ func distributor(p golParams, d distributorChans, alive chan []cell) {
 
 	terminate := false
 
-	for turns := 0; turns < p.turns && terminate == false; turns++ {
+	for turns := 0; turns < p.turns && terminate == false; {
 
 		alivecells = 0
 
@@ -93,16 +83,16 @@ func distributor(p golParams, d distributorChans, alive chan []cell) {
 			case 'p':
 				fmt.Println("Paused")
 				var resume rune
-				pause = true
 				for resume != 'p' {
 					resume = <-d.key
-					pause = false
 				}
 				fmt.Println("Continuing")
 			case 'q':
 				fmt.Println("Terminate and generate PGM")
 				terminate = true
 			}
+		case <-ticker.C:
+			fmt.Println("Turn", turns, "alive:", alivecells)
 		//case s := <-nocellsalive:
 		//	fmt.Printf("%v\n", s)
 		default:
@@ -177,6 +167,7 @@ func distributor(p golParams, d distributorChans, alive chan []cell) {
 
 			//RECONSTRUCT WORLD
 			world = tempWorld
+			turns++
 		}
 
 	}
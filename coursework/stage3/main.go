@@ -1,6 +1,9 @@
 package main
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
 
 // golParams provides the details of how to run the Game of Life and which image to load.
 type golParams struct {
@@ -8,6 +11,9 @@ type golParams struct {
 	threads     int
 	imageWidth  int
 	imageHeight int
+
+	// aliveInterval is how often the number of alive cells is reported. 0 means defaultAliveInterval.
+	aliveInterval time.Duration
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
@@ -69,6 +75,10 @@ type ioChans struct {
 // It places the created channels in the relevant structs.
 // It returns an array of alive cells returned by the distributor.
 func gameOfLife(p golParams, keyChan <-chan rune) []cell {
+	if p.aliveInterval == 0 {
+		p.aliveInterval = defaultAliveInterval
+	}
+
 	var dChans distributorChans
 	var ioChans ioChans
 
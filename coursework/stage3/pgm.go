@@ -41,7 +41,7 @@ func writePgmImage(p golParams, i ioChans) {
 	// TODO: write a for-loop to receive the world from the distributor when outputting.
 	for y := 0; y < p.imageHeight; y++ {
 		for x := 0; x < p.imageWidth; x++ {
-			world[x][y] = <-i.distributor.outputVal
+			world[y][x] = <-i.distributor.outputVal
 		}
 	}
 
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/reference"
+)
+
+// BenchmarkSweep times the engine on one size and number of threads, for the sweep command.
+// See the reference package for the flags that pick them.
+func BenchmarkSweep(b *testing.B) {
+	reference.Sweep(b, nil, engine)
+}
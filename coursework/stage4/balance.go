@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+// sections is how the rows of the world are shared between the workers: worker i calculates
+// rows sections[i] to sections[i+1]-1. It always starts at 0 and ends at the height of the image.
+type sections []int
+
+// staticSections shares the rows out as evenly as they go, with the first workers taking one extra row
+// when the threads do not divide the height.
+func staticSections(p golParams) sections {
+	s := make(sections, p.threads+1)
+	for i := 0; i < p.threads; i++ {
+		height := p.imageHeight / p.threads
+		if i < p.imageHeight%p.threads {
+			height++
+		}
+		s[i+1] = s[i] + height
+	}
+	return s
+}
+
+// height is the number of rows worker i calculates.
+func (s sections) height(i int) int {
+	return s[i+1] - s[i]
+}
+
+// rebalanceTolerance is how much longer than the average the slowest worker may take before rows are moved.
+// Below it, the differences are as likely to be noise in the timings as real.
+const rebalanceTolerance = 0.05
+
+// balanceSections moves the boundaries between the workers so that each would have taken the same time,
+// given the compute time each took for its rows since the last rebalance.
+// The rows of a worker are taken to cost the same as each other, so the cost of the world builds up row by row,
+// and each boundary goes where its share of the total cost is reached.
+//
+// Rows only move between neighbours: each boundary stays inside the sections either side of it,
+// so every worker keeps at least one of its rows. The boundary at the top of the world, which
+// is also the bottom, never moves.
+func balanceSections(s sections, compute []time.Duration) sections {
+	workers := len(s) - 1
+	var total, slowest time.Duration
+	for _, c := range compute {
+		total += c
+		if c > slowest {
+			slowest = c
+		}
+	}
+	if workers < 2 || total == 0 || float64(slowest)*float64(workers) <= float64(total)*(1+rebalanceTolerance) {
+		return s
+	}
+
+	// The cost of all the rows above each row.
+	above := make([]float64, s[workers]+1)
+	for w := 0; w < workers; w++ {
+		rowCost := float64(compute[w]) / float64(s.height(w))
+		for y := s[w]; y < s[w+1]; y++ {
+			above[y+1] = above[y] + rowCost
+		}
+	}
+
+	balanced := append(sections(nil), s...)
+	for j := 1; j < workers; j++ {
+		target := above[s[workers]] * float64(j) / float64(workers)
+		y := sort.SearchFloat64s(above, target)
+		if y > 0 && target-above[y-1] < above[y]-target {
+			y--
+		}
+		y = max(y, max(s[j-1], balanced[j-1])+1)
+		if high := s[j+1] - 1; y > high {
+			y = high
+		}
+		balanced[j] = y
+	}
+	return balanced
+}
+
+// rowMove tells a worker how its section changes in a rebalance.
+// top and bottom are the rows it gains at each edge from its neighbours, or gives them if they are negative.
+type rowMove struct {
+	top, bottom int
+}
+
+// moveRows has the workers move rows between each other to go from the old sections to the new ones.
+// Only the workers whose sections change take part, and the rest are left as they are.
+func moveRows(p golParams, d distributorChans, from, to sections) {
+	for i := 0; i < p.threads; i++ {
+		move := rowMove{top: from[i] - to[i], bottom: to[i+1] - from[i+1]}
+		if move == (rowMove{}) {
+			continue
+		}
+		d.workerCommands[i] <- workerRebalance
+		d.workerMoves[i] <- move
+	}
+}
+
+// sendRows sends rows of cells to a neighbour, one byte at a time.
+func sendRows(to chan<- uint8, rows [][]byte) {
+	for _, row := range rows {
+		for _, b := range row {
+			to <- b
+		}
+	}
+}
+
+// receiveRows receives n rows of cells from a neighbour.
+func receiveRows(from <-chan uint8, n, width int) [][]byte {
+	rows := make([][]byte, n)
+	for i := range rows {
+		rows[i] = make([]byte, width)
+		for x := range rows[i] {
+			rows[i][x] = <-from
+		}
+	}
+	return rows
+}
+
+// sendCounts sends rows of heat map counts to a neighbour, four bytes to a count.
+func sendCounts(to chan<- uint8, rows [][]uint32) {
+	var b [4]byte
+	for _, row := range rows {
+		for _, count := range row {
+			binary.BigEndian.PutUint32(b[:], count)
+			for _, v := range b {
+				to <- v
+			}
+		}
+	}
+}
+
+// receiveCounts receives n rows of heat map counts from a neighbour.
+func receiveCounts(from <-chan uint8, n, width int) [][]uint32 {
+	rows := make([][]uint32, n)
+	var b [4]byte
+	for i := range rows {
+		rows[i] = make([]uint32, width)
+		for x := range rows[i] {
+			for j := range b {
+				b[j] = <-from
+			}
+			rows[i][x] = binary.BigEndian.Uint32(b[:])
+		}
+	}
+	return rows
+}
+
+// section is the part of the world a worker holds: its rows, with the halo rows either side of them
+// in world[0] and world[height+1], and the heat map counts of its rows if they are being kept.
+type section struct {
+	startY, height    int
+	world             [][]byte
+	aliveTurns, flips [][]uint32
+}
+
+// move gives rows to the neighbours or takes rows from them, and updates the halos to match.
+// The rows sent to a neighbour come with the row next to them, which becomes the neighbour's new halo.
+//
+// Every worker sends before it receives. A worker waiting for a neighbour to take its rows is only waiting
+// on workers further up, or further down, the world, and the boundary at the top never moves, so the waiting
+// always comes to an end.
+func (s section) move(p golParams, halos workerHalos, move rowMove) section {
+	heat := s.aliveTurns != nil
+	if move.top < 0 {
+		give := -move.top
+		sendRows(halos.sendTop, s.world[1:give+2])
+		if heat {
+			sendCounts(halos.sendTop, s.aliveTurns[:give])
+			sendCounts(halos.sendTop, s.flips[:give])
+		}
+	}
+	if move.bottom < 0 {
+		give := -move.bottom
+		sendRows(halos.sendBottom, s.world[s.height-give:s.height+1])
+		if heat {
+			sendCounts(halos.sendBottom, s.aliveTurns[s.height-give:])
+			sendCounts(halos.sendBottom, s.flips[s.height-give:])
+		}
+	}
+
+	// The rows kept, with the halo rows they have now.
+	keepFrom, keepTo := 1+max(0, -move.top), s.height-max(0, -move.bottom)
+	topHalo, bottomHalo := s.world[keepFrom-1], s.world[keepTo+1]
+	world := s.world[keepFrom : keepTo+1]
+	aliveTurns, flips := s.aliveTurns, s.flips
+	if heat {
+		aliveTurns, flips = aliveTurns[keepFrom-1:keepTo], flips[keepFrom-1:keepTo]
+	}
+
+	if move.top > 0 {
+		rows := receiveRows(halos.top, move.top+1, p.imageWidth)
+		topHalo, world = rows[0], append(rows[1:], world...)
+		if heat {
+			aliveTurns = append(receiveCounts(halos.top, move.top, p.imageWidth), aliveTurns...)
+			flips = append(receiveCounts(halos.top, move.top, p.imageWidth), flips...)
+		}
+	}
+	if move.bottom > 0 {
+		rows := receiveRows(halos.bottom, move.bottom+1, p.imageWidth)
+		world, bottomHalo = append(append([][]byte(nil), world...), rows[:move.bottom]...), rows[move.bottom]
+		if heat {
+			aliveTurns = append(append([][]uint32(nil), aliveTurns...), receiveCounts(halos.bottom, move.bottom, p.imageWidth)...)
+			flips = append(append([][]uint32(nil), flips...), receiveCounts(halos.bottom, move.bottom, p.imageWidth)...)
+		}
+	}
+
+	return section{
+		startY:     s.startY - move.top,
+		height:     len(world),
+		world:      append(append([][]byte{topHalo}, world...), bottomHalo),
+		aliveTurns: aliveTurns,
+		flips:      flips,
+	}
+}
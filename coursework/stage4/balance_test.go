@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertSections checks that the sections cover the world in order, with at least one row each,
+// and that every boundary stays inside the sections either side of it before the move.
+func assertSections(t *testing.T, from, to sections) {
+	assert.Len(t, to, len(from))
+	assert.Equal(t, from[0], to[0])
+	assert.Equal(t, from[len(from)-1], to[len(to)-1])
+	for j := 1; j < len(to)-1; j++ {
+		assert.True(t, to[j] > to[j-1], "%v: worker %d has no rows", to, j-1)
+		assert.True(t, to[j] > from[j-1] && to[j] < from[j+1], "%v: boundary %d moved past a neighbour of %v", to, j, from)
+	}
+}
+
+func TestBalanceSections(t *testing.T) {
+	ms := time.Millisecond
+
+	t.Run("expensive rows", func(t *testing.T) {
+		// Worker 0 took three times as long for the same rows, so its rows go to worker 1 until the cost is even.
+		s := sections{0, 32, 64}
+		balanced := balanceSections(s, []time.Duration{30 * ms, 10 * ms})
+		assertSections(t, s, balanced)
+		assert.Equal(t, sections{0, 21, 64}, balanced)
+	})
+
+	t.Run("one slow worker in the middle", func(t *testing.T) {
+		// Worker 2 gives rows to both its neighbours, and worker 1 passes some of its own on to worker 0
+		// to make room for them.
+		s := sections{0, 16, 32, 48, 64}
+		balanced := balanceSections(s, []time.Duration{10 * ms, 10 * ms, 40 * ms, 10 * ms})
+		assertSections(t, s, balanced)
+		assert.Equal(t, sections{0, 28, 38, 45, 64}, balanced)
+	})
+
+	t.Run("rows only move between neighbours", func(t *testing.T) {
+		// Balancing would take most of worker 1's rows and all of worker 2's, but each boundary
+		// can only move within the sections either side of it.
+		s := sections{0, 4, 8, 12}
+		balanced := balanceSections(s, []time.Duration{1 * ms, 1 * ms, 100 * ms})
+		assertSections(t, s, balanced)
+		assert.Equal(t, sections{0, 7, 11, 12}, balanced)
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		s := sections{0, 22, 43, 64}
+		assert.Equal(t, s, balanceSections(s, []time.Duration{10 * ms, 10 * ms, 10 * ms}), "already balanced")
+		assert.Equal(t, s, balanceSections(s, []time.Duration{10 * ms, 10 * ms, 10*ms + 100*time.Microsecond}), "within the tolerance")
+		assert.Equal(t, s, balanceSections(s, []time.Duration{0, 0, 0}), "no timings")
+		assert.Equal(t, sections{0, 64}, balanceSections(sections{0, 64}, []time.Duration{10 * ms}), "one worker")
+	})
+}
+
+// randomSections moves every boundary to a random row that it could move to in a rebalance.
+func randomSections(r *rand.Rand) func(s sections, compute []time.Duration) sections {
+	return func(s sections, compute []time.Duration) sections {
+		moved := append(sections(nil), s...)
+		for j := 1; j < len(s)-1; j++ {
+			low := max(s[j-1], moved[j-1]) + 1
+			moved[j] = low + r.Intn(s[j+1]-low)
+		}
+		return moved
+	}
+}
+
+// TestRebalance moves rows between the workers at random after every turn,
+// and checks that the run finishes with the same world and heat maps as without moving them.
+func TestRebalance(t *testing.T) {
+	for _, size := range []int{16, 64} {
+		for _, threads := range []int{1, 2, 3, 5, 8} {
+			for _, bounded := range []bool{false, true} {
+				p := golParams{
+					turns:       40,
+					threads:     threads,
+					imageWidth:  size,
+					imageHeight: size,
+					bounded:     bounded,
+					heatMap:     true,
+				}
+				name := strconv.Itoa(size) + "x" + strconv.Itoa(size) + ", " + strconv.Itoa(threads) + " threads, bounded " + strconv.FormatBool(bounded)
+				cleanOutput(t)
+				heatPath := "out/" + strconv.Itoa(size) + "x" + strconv.Itoa(size) + "_40_"
+
+				static := gameOfLife(p, nil, nil)
+				staticAlive, staticFlips := readHeatPgm(t, heatPath+"alive.pgm"), readHeatPgm(t, heatPath+"flips.pgm")
+
+				p.test.chooseSections = randomSections(rand.New(rand.NewSource(int64(size * threads))))
+				p.rebalanceEvery = 1
+				moved := gameOfLife(p, nil, nil)
+
+				assert.ElementsMatch(t, static, moved, name)
+				assert.ElementsMatch(t, aliveCells(referenceWorlds(p, p.turns)[p.turns]), moved, name)
+				assert.Equal(t, staticAlive, readHeatPgm(t, heatPath+"alive.pgm"), name)
+				assert.Equal(t, staticFlips, readHeatPgm(t, heatPath+"flips.pgm"), name)
+			}
+		}
+	}
+}
+
+// TestRebalanceKeys resets the run and steps back through it after rows have moved,
+// both of which load the world again into the sections as they are then.
+func TestRebalanceKeys(t *testing.T) {
+	p := golParams{
+		turns:          30,
+		threads:        4,
+		imageWidth:     64,
+		imageHeight:    64,
+		rebalanceEvery: 3,
+	}
+	p.test.chooseSections = randomSections(rand.New(rand.NewSource(1)))
+	cleanOutput(t)
+
+	var alive []cell
+	captureStdout(func() {
+		alive = runScript(p, []scriptStep{{10, "r"}, {20, "pbbbp"}}, nil)
+	})
+	assert.ElementsMatch(t, aliveCells(referenceWorlds(p, p.turns)[p.turns]), alive)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// knownObject is a still life, oscillator or spaceship that the census can name.
+// Its shape is given for one phase, with 'O' for alive cells, and rows separated by '/'.
+type knownObject struct {
+	name   string
+	period int
+	shape  string
+}
+
+// knownObjects are the objects most often left behind by a run.
+var knownObjects = []knownObject{
+	{"block", 1, "OO/OO"},
+	{"beehive", 1, ".OO./O..O/.OO."},
+	{"loaf", 1, ".OO./O..O/.O.O/..O."},
+	{"boat", 1, "OO./O.O/.O."},
+	{"ship", 1, "OO./O.O/.OO"},
+	{"tub", 1, ".O./O.O/.O."},
+	{"pond", 1, ".OO./O..O/O..O/.OO."},
+	{"long boat", 1, "OO../O.O./.O.O/..O."},
+	{"barge", 1, ".O../O.O./.O.O/..O."},
+	{"snake", 1, "OO.O/O.OO"},
+	{"blinker", 2, "OOO"},
+	{"toad", 2, ".OOO/OOO."},
+	{"beacon", 2, "OO../OO../..OO/..OO"},
+	{"glider", 4, ".O./..O/OOO"},
+	{"lightweight spaceship", 4, ".O..O/O..../O...O/OOOO."},
+	{"middleweight spaceship", 4, "..O.../O...O./.....O/O....O/.OOOOO"},
+	{"heavyweight spaceship", 4, "..OO.../O....O./......O/O.....O/.OOOOOO"},
+}
+
+// knownShapes maps the canonical form of every phase of the known objects to the object's name.
+// splitShapes does the same for the phases that fall apart into separate pieces, such as one of the beacon's,
+// which the census only finds by putting pieces that are within two cells of each other back together.
+var knownShapes, splitShapes = func() (map[string]string, map[string]string) {
+	shapes := make(map[string]string)
+	split := make(map[string]string)
+	for _, object := range knownObjects {
+		cells := placeShape(object.shape, 0, 0)
+		for phase := 0; phase < object.period; phase++ {
+			if len(connectedComponents(cells, 0, 0, 1)) == 1 {
+				shapes[canonicalShape(cells)] = object.name
+			} else {
+				split[canonicalShape(cells)] = object.name
+			}
+			cells = stepCells(cells)
+		}
+	}
+	return shapes, split
+}()
+
+// censusEntry counts the objects of one kind on the board.
+// Objects that are not known are grouped by their number of cells.
+type censusEntry struct {
+	name  string
+	cells int
+	count int
+}
+
+// census splits the alive cells into objects and counts each kind of object.
+// Cells belong to the same object if they touch, including diagonally and across the edges of the board,
+// or if they are pieces of a phase of a known object that falls apart.
+// The entries are sorted with the most common objects first.
+func census(alive []cell, width, height int) []censusEntry {
+	counts := make(map[censusEntry]int)
+	var unknown []cell
+	for _, object := range connectedComponents(alive, width, height, 1) {
+		if name, ok := knownShapes[canonicalShape(object)]; ok {
+			counts[censusEntry{name: name, cells: len(object)}]++
+		} else {
+			unknown = append(unknown, object...)
+		}
+	}
+
+	// Pieces that are not known on their own may together be a phase that falls apart.
+	for _, group := range connectedComponents(unknown, width, height, 2) {
+		if name, ok := splitShapes[canonicalShape(group)]; ok {
+			counts[censusEntry{name: name, cells: len(group)}]++
+			continue
+		}
+		for _, piece := range connectedComponents(group, 0, 0, 1) {
+			counts[censusEntry{name: "unidentified", cells: len(piece)}]++
+		}
+	}
+
+	var entries []censusEntry
+	for entry, count := range counts {
+		entry.count = count
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.cells < b.cells
+	})
+	return entries
+}
+
+// formatCensus lays the census out as a table.
+func formatCensus(entries []censusEntry) string {
+	nameWidth := len("Object")
+	for _, entry := range entries {
+		if len(entry.name) > nameWidth {
+			nameWidth = len(entry.name)
+		}
+	}
+
+	var table strings.Builder
+	line := func(name, cells, count string) {
+		table.WriteString(name + strings.Repeat(" ", nameWidth-len(name)) + "  " + fmt.Sprintf("%5s  %5s", cells, count) + "\n")
+	}
+	line("Object", "Cells", "Count")
+	total := 0
+	for _, entry := range entries {
+		line(entry.name, strconv.Itoa(entry.cells), strconv.Itoa(entry.count))
+		total += entry.count
+	}
+	line("Total", "", strconv.Itoa(total))
+	return table.String()
+}
+
+// connectedComponents groups cells that are at most reach cells apart, across, down or diagonally.
+// With a reach of 1 the cells in a group touch. On a board with a width and height the groups wrap around the edges, and each group's
+// cells are given relative to one another so that a group split by an edge keeps its shape.
+// A width and height of 0 means the cells are on an unbounded plane.
+func connectedComponents(alive []cell, width, height, reach int) [][]cell {
+	wrap := func(c cell) cell {
+		if width > 0 {
+			c.x = (c.x%width + width) % width
+		}
+		if height > 0 {
+			c.y = (c.y%height + height) % height
+		}
+		return c
+	}
+
+	unvisited := make(map[cell]bool, len(alive))
+	for _, c := range alive {
+		unvisited[wrap(c)] = true
+	}
+
+	var components [][]cell
+	for _, start := range alive {
+		start = wrap(start)
+		if !unvisited[start] {
+			continue
+		}
+		delete(unvisited, start)
+
+		// Search outwards from the start, remembering where each cell is relative to it.
+		component := []cell{start}
+		for i := 0; i < len(component); i++ {
+			c := component[i]
+			for dy := -reach; dy <= reach; dy++ {
+				for dx := -reach; dx <= reach; dx++ {
+					next := cell{x: c.x + dx, y: c.y + dy}
+					if unvisited[wrap(next)] {
+						delete(unvisited, wrap(next))
+						component = append(component, next)
+					}
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// canonicalShape describes the shape of the cells the same way whatever their position, rotation or reflection.
+// It tries all 8 ways of rotating and reflecting the cells and keeps the smallest description.
+func canonicalShape(cells []cell) string {
+	best := ""
+	for transform := 0; transform < 8; transform++ {
+		moved := make([]cell, len(cells))
+		for i, c := range cells {
+			x, y := c.x, c.y
+			if transform&1 != 0 {
+				x = -x
+			}
+			if transform&2 != 0 {
+				y = -y
+			}
+			if transform&4 != 0 {
+				x, y = y, x
+			}
+			moved[i] = cell{x: x, y: y}
+		}
+
+		minX, minY := moved[0].x, moved[0].y
+		for _, c := range moved {
+			if c.x < minX {
+				minX = c.x
+			}
+			if c.y < minY {
+				minY = c.y
+			}
+		}
+		sort.Slice(moved, func(i, j int) bool {
+			if moved[i].y != moved[j].y {
+				return moved[i].y < moved[j].y
+			}
+			return moved[i].x < moved[j].x
+		})
+
+		var shape strings.Builder
+		for _, c := range moved {
+			shape.WriteString(strconv.Itoa(c.x-minX) + "," + strconv.Itoa(c.y-minY) + ";")
+		}
+		if best == "" || shape.String() < best {
+			best = shape.String()
+		}
+	}
+	return best
+}
+
+// stepCells calculates one turn for cells on an unbounded plane.
+func stepCells(alive []cell) []cell {
+	isAlive := make(map[cell]bool, len(alive))
+	neighbours := make(map[cell]int)
+	for _, c := range alive {
+		isAlive[c] = true
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx != 0 || dy != 0 {
+					neighbours[cell{x: c.x + dx, y: c.y + dy}]++
+				}
+			}
+		}
+	}
+
+	var next []cell
+	for c, n := range neighbours {
+		if n == 3 || (n == 2 && isAlive[c]) {
+			next = append(next, c)
+		}
+	}
+	return next
+}
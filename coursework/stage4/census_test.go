@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// placeTurned is placeShape with the shape optionally turned a quarter turn first.
+func placeTurned(shape string, x, y int, rotate bool) []cell {
+	var cells []cell
+	for dy, row := range strings.Split(shape, "/") {
+		for dx, c := range row {
+			if c != 'O' {
+				continue
+			}
+			if rotate {
+				cells = append(cells, cell{x: x - dy, y: y + dx})
+			} else {
+				cells = append(cells, cell{x: x + dx, y: y + dy})
+			}
+		}
+	}
+	return cells
+}
+
+func TestCensus(t *testing.T) {
+	t.Run("constructed board", func(t *testing.T) {
+		var alive []cell
+		alive = append(alive, placeTurned("OO/OO", 2, 2, false)...)
+		alive = append(alive, placeTurned("OO/OO", 10, 2, false)...)
+		// This block is split across the left and right edges.
+		alive = append(alive, placeTurned("OO/OO", 63, 20, false)...)
+		alive = append(alive, placeTurned(".OO./O..O/.OO.", 20, 2, true)...)
+		alive = append(alive, placeTurned(".OO./O..O/.O.O/..O.", 30, 2, false)...)
+		alive = append(alive, placeTurned("OO./O.O/.O.", 40, 2, true)...)
+		alive = append(alive, placeTurned("OOO", 2, 10, false)...)
+		alive = append(alive, placeTurned("OOO", 10, 10, true)...)
+		alive = append(alive, placeTurned("OOO", 20, 10, false)...)
+		// This glider is split across the top and bottom edges, and reflected.
+		alive = append(alive, placeTurned("O../O.O/OO.", 50, 63, false)...)
+		alive = append(alive, placeTurned("OO../OO../..OO/..OO", 40, 10, false)...)
+		// The other phase of the beacon is two pieces of three cells, turned and split across the top and bottom edges.
+		alive = append(alive, placeTurned("OO../O.../...O/..OO", 60, 62, true)...)
+		// A row of five is not a known object.
+		alive = append(alive, placeTurned("OOOOO", 2, 30, false)...)
+		for i := range alive {
+			alive[i] = cell{x: (alive[i].x + 64) % 64, y: (alive[i].y + 64) % 64}
+		}
+
+		assert.Equal(t, []censusEntry{
+			{name: "blinker", cells: 3, count: 3},
+			{name: "block", cells: 4, count: 3},
+			{name: "beacon", cells: 6, count: 1},
+			{name: "beacon", cells: 8, count: 1},
+			{name: "beehive", cells: 6, count: 1},
+			{name: "boat", cells: 5, count: 1},
+			{name: "glider", cells: 5, count: 1},
+			{name: "loaf", cells: 7, count: 1},
+			{name: "unidentified", cells: 5, count: 1},
+		}, census(alive, 64, 64))
+	})
+
+	t.Run("table", func(t *testing.T) {
+		table := formatCensus([]censusEntry{{name: "block", cells: 4, count: 3}, {name: "glider", cells: 5, count: 1}})
+		assert.Equal(t, ""+
+			"Object  Cells  Count\n"+
+			"block       4      3\n"+
+			"glider      5      1\n"+
+			"Total              4\n", table)
+	})
+
+	t.Run("known objects", func(t *testing.T) {
+		for _, object := range knownObjects {
+			// Every phase is counted as the object, including those that fall apart into pieces.
+			cells := placeShape(object.shape, 0, 0)
+			for phase := 0; phase < object.period; phase++ {
+				assert.Equal(t, []censusEntry{{name: object.name, cells: len(cells), count: 1}}, census(cells, 0, 0), "%s phase %d", object.name, phase)
+				cells = stepCells(cells)
+			}
+
+			// Each object comes back to the same shape after its period.
+			assert.Equal(t, canonicalShape(placeShape(object.shape, 0, 0)), canonicalShape(cells), object.name)
+		}
+	})
+
+	t.Run("after a run", func(t *testing.T) {
+		p := golParams{
+			turns:       100,
+			threads:     4,
+			imageWidth:  16,
+			imageHeight: 16,
+		}
+		cleanOutput(t)
+		alive := gameOfLife(p, nil, nil)
+		assert.Equal(t, []censusEntry{{name: "glider", cells: 5, count: 1}}, census(alive, p.imageWidth, p.imageHeight))
+	})
+}
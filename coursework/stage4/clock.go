@@ -0,0 +1,120 @@
+package main
+
+import "time"
+
+// clock is where the distributor gets the time from, to keep turns to the speed limit and to report the alive cells.
+// The tests use one in which time only passes when a turn waits for it.
+type clock interface {
+	Now() time.Time
+
+	// NewTicker returns a chan that is sent the time every d, and a function that stops it.
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+
+	// NewTimer returns a timer that does not fire until it is reset.
+	NewTimer() clockTimer
+}
+
+// clockTimer is a timer that can be started again, so that one does for every turn.
+type clockTimer interface {
+	C() <-chan time.Time
+
+	// Reset starts the timer again, to fire once after d, forgetting any time it had fired or would have.
+	Reset(d time.Duration)
+
+	Stop()
+}
+
+// realClock tells the time of the machine.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+func (realClock) NewTimer() clockTimer {
+	timer := time.NewTimer(time.Hour)
+	t := realTimer{timer}
+	t.Stop()
+	return t
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Reset(d time.Duration) {
+	t.Stop()
+	t.timer.Reset(d)
+}
+
+// Stop stops the timer and empties its chan, so that a time it sent before is not taken for the next one.
+func (t realTimer) Stop() {
+	if !t.timer.Stop() {
+		select {
+		case <-t.timer.C:
+		default:
+		}
+	}
+}
+
+// unlimited is always ready, so turns run back to back when there is no speed limit.
+var unlimited = func() chan time.Time {
+	c := make(chan time.Time)
+	close(c)
+	return c
+}()
+
+// pacer holds turns back to the speed set with '+' and '-'.
+// It keeps one timer, which is started again for each turn that has to wait.
+type pacer struct {
+	clock clock
+	timer clockTimer
+
+	// rate is the index of the speed in turnRates, or len(turnRates) when there is no limit.
+	rate int
+
+	lastTurn time.Time
+	running  bool
+}
+
+func newPacer(c clock) *pacer {
+	return &pacer{clock: c, timer: c.NewTimer(), rate: len(turnRates)}
+}
+
+// due returns a chan that is ready once the next turn may start.
+func (pc *pacer) due() <-chan time.Time {
+	if pc.rate == len(turnRates) {
+		return unlimited
+	}
+	if !pc.running {
+		next := pc.lastTurn.Add(time.Second / time.Duration(turnRates[pc.rate]))
+		pc.timer.Reset(next.Sub(pc.clock.Now()))
+		pc.running = true
+	}
+	return pc.timer.C()
+}
+
+// turnStarted counts the time to the next turn from now.
+func (pc *pacer) turnStarted() {
+	pc.lastTurn = pc.clock.Now()
+	pc.running = false
+}
+
+// setRate changes the speed limit, which the next turn keeps to.
+func (pc *pacer) setRate(rate int) {
+	pc.rate = rate
+	pc.running = false
+}
+
+func (pc *pacer) stop() {
+	pc.timer.Stop()
+}
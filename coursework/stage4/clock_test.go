@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a clock in which time only passes when a turn waits for it, so the wait is over straight away.
+// Its ticker is always ready.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+
+	// waits are the times the turns were held back for, in order.
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(time.Duration) (<-chan time.Time, func()) {
+	return unlimited, func() {}
+}
+
+func (c *fakeClock) NewTimer() clockTimer {
+	return &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+}
+
+// lastWaits returns the last n waits, which are those of the last n turns when the speed did not change in between.
+func (c *fakeClock) lastWaits(n int) []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.waits) < n {
+		return nil
+	}
+	return append([]time.Duration(nil), c.waits[len(c.waits)-n:]...)
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Reset(d time.Duration) {
+	t.Stop()
+	t.clock.mu.Lock()
+	t.clock.waits = append(t.clock.waits, d)
+	if d > 0 {
+		t.clock.now = t.clock.now.Add(d)
+	}
+	now := t.clock.now
+	t.clock.mu.Unlock()
+	t.c <- now
+}
+
+func (t *fakeTimer) Stop() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
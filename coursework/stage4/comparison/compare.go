@@ -1,22 +1,40 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"text/tabwriter"
 )
 
-type bench struct {
-	name   string
-	result int64
+// results holds every measurement of each benchmark.
+type results struct {
+	// names are the benchmarks in the order they first appear.
+	names  []string
+	values map[string][]float64
+
+	// gomaxprocs and cpu are what the benchmarks ran with, as 'go test' reported them.
+	// cpu is "" if it was not reported.
+	gomaxprocs int
+	cpu        string
 }
 
-type time struct {
-	result int
+func newResults() *results {
+	return &results{values: make(map[string][]float64)}
+}
+
+func (r *results) add(name string, value float64) {
+	if _, ok := r.values[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.values[name] = append(r.values[name], value)
 }
 
 func check(e error) {
@@ -25,90 +43,290 @@ func check(e error) {
 	}
 }
 
-func readCpuTimes(file []byte) []time {
-	numberRegex, _ := regexp.Compile(`\d+`)
-	rows := numberRegex.FindAllString(string(file), -1)
-	times := make([]time, len(rows))
-	for i, row := range rows {
-		result, err := strconv.Atoi(row)
-		check(err)
-		times[i] = time{result:result}
+// benchmarkName shortens a name like Benchmark/512x512x8-12 to 512x512x8, leaving out the GOMAXPROCS suffix.
+func benchmarkName(name string) string {
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "Benchmark"), "/")
+	if dash := strings.LastIndex(name, "-"); dash >= 0 {
+		if _, err := strconv.Atoi(name[dash+1:]); err == nil {
+			name = name[:dash]
+		}
 	}
-	return times
+	return name
 }
 
-//noinspection GoUnhandledErrorResult
-func analyseCpuTimes() {
-	baseBenchmarksFile, err := ioutil.ReadFile(os.Args[3])
-	check(err)
-	baseBenchmarks := readBenchmarks(baseBenchmarksFile)
+// benchmarkProcs reads the GOMAXPROCS a benchmark ran with from the suffix of its name, such as 12 in
+// Benchmark/512x512x8-12. 'go test' leaves the suffix out when GOMAXPROCS is 1.
+func benchmarkProcs(name string) int {
+	if dash := strings.LastIndex(name, "-"); dash >= 0 {
+		if procs, err := strconv.Atoi(name[dash+1:]); err == nil {
+			return procs
+		}
+	}
+	return 1
+}
 
-	baseCpuTimesFile, err := ioutil.ReadFile(os.Args[1])
-	check(err)
-	newCpuTimesFile, err := ioutil.ReadFile(os.Args[2])
-	check(err)
-	baseTimes := readCpuTimes(baseCpuTimesFile)
-	newTimes := readCpuTimes(newCpuTimesFile)
+// testEvent is the part of a 'go test -json' event that holds the output.
+type testEvent struct {
+	Action string
+	Output string
+}
 
-	if len(baseTimes) != len(newTimes) {
-		panic("CPU time lengths don't match")
+// readBenchmarks reads the ns/op of each benchmark run from the output of 'go test -bench', with or without -json.
+// JSON output is a stream of events, and their output is joined back together before it is read, because a
+// benchmark's name and its result are often in different events.
+func readBenchmarks(data []byte) (*results, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var output strings.Builder
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		for decoder.More() {
+			var event testEvent
+			if err := decoder.Decode(&event); err != nil {
+				return nil, err
+			}
+			if event.Action == "output" {
+				output.WriteString(event.Output)
+			}
+		}
+		data = []byte(output.String())
+	}
+
+	// Each result is a line holding the name, the number of iterations, and then pairs of a value and its unit.
+	// The CPU is on a line of its own before them.
+	r := newResults()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if cpu := strings.TrimPrefix(scanner.Text(), "cpu: "); cpu != scanner.Text() {
+			r.cpu = strings.TrimSpace(cpu)
+			continue
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		// Results with different GOMAXPROCS have the same name once it is left out, and cannot be put together.
+		procs := benchmarkProcs(fields[0])
+		if r.gomaxprocs != 0 && procs != r.gomaxprocs {
+			return nil, fmt.Errorf("%s ran with GOMAXPROCS=%d, but the benchmarks before it with GOMAXPROCS=%d", fields[0], procs, r.gomaxprocs)
+		}
+		r.gomaxprocs = procs
+		for i := 2; i+1 < len(fields); i += 2 {
+			if fields[i+1] == "ns/op" {
+				value, err := strconv.ParseFloat(fields[i], 64)
+				if err != nil {
+					return nil, fmt.Errorf("bad result %q for %s", fields[i], fields[0])
+				}
+				r.add(benchmarkName(fields[0]), value)
+			}
+		}
+	}
+	if len(r.names) == 0 {
+		return nil, errors.New("no benchmark results found")
+	}
+	return r, scanner.Err()
+}
+
+// readCpuTimes reads the CPU usage of each benchmark run, written by compare.sh as lines like '512x512x8 581%'.
+// Older files only have the percentage, one line for each of the given benchmarks in turn.
+func readCpuTimes(data []byte, names []string) (*results, error) {
+	r := newResults()
+	lines := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var name string
+		if len(fields) == 2 {
+			name = fields[0]
+		} else if len(names) > 0 {
+			name = names[lines%len(names)]
+		}
+		value, err := strconv.ParseFloat(strings.TrimSuffix(fields[len(fields)-1], "%"), 64)
+		if err != nil || name == "" {
+			return nil, fmt.Errorf("bad CPU usage %q", scanner.Text())
+		}
+		r.add(name, value)
+		lines++
+	}
+	return r, scanner.Err()
+}
+
+// comparison is how one benchmark did in the baseline and in your solution.
+type comparison struct {
+	name       string
+	base, ours summary
+
+	// p is the probability of a difference this big if there were no real one.
+	p float64
+}
+
+// significant reports whether the difference is unlikely to be chance.
+func (c comparison) significant() bool {
+	return c.p < *alpha
+}
+
+// compare pairs up the benchmarks in the baseline and your solution by name.
+// Benchmarks only in one of them are listed separately.
+func compare(base, ours *results) (comparisons []comparison, missing []string) {
+	for _, name := range base.names {
+		if _, ok := ours.values[name]; !ok {
+			missing = append(missing, name+" is only in the baseline")
+			continue
+		}
+		c := comparison{name: name, base: summarise(base.values[name]), ours: summarise(ours.values[name])}
+		c.p = welch(c.base, c.ours)
+		comparisons = append(comparisons, c)
+	}
+	for _, name := range ours.names {
+		if _, ok := base.values[name]; !ok {
+			missing = append(missing, name+" is only in your results")
+		}
+	}
+	return comparisons, missing
+}
+
+// describe formats a summary as mean ± confidence interval, with the number of runs.
+func describe(s summary, unit string) string {
+	return fmt.Sprintf("%.0f%s ± %.1f%% (n=%d)", s.mean, unit, 100*s.relativeCI(), s.n)
+}
+
+// regressed reports whether your solution is slower than the baseline by more than threshold percent,
+// and the difference is significant. With only one run of either there is no telling, so the slow-down alone decides.
+func (c comparison) regressed(threshold float64) bool {
+	if c.ours.mean <= c.base.mean*(1+threshold/100) {
+		return false
+	}
+	return c.significant() || c.base.n < 2 || c.ours.n < 2
+}
+
+// verdict says whether a difference is real, and whether it is good or bad.
+// Lower is better for times, and higher for CPU usage.
+func verdict(c comparison, lowerIsBetter bool) string {
+	if lowerIsBetter && c.regressed(*threshold) {
+		return fmt.Sprintf("REGRESSION (p=%.3f)", c.p)
+	}
+	if !c.significant() {
+		return fmt.Sprintf("no significant difference (p=%.2f)", c.p)
 	}
+	if (c.ours.mean > c.base.mean) == lowerIsBetter {
+		return fmt.Sprintf("worse, within the threshold (p=%.3f)", c.p)
+	}
+	return fmt.Sprintf("improvement (p=%.3f)", c.p)
+}
+
+func printComparisons(comparisons []comparison, missing []string, unit string, lowerIsBetter bool) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
 	defer w.Flush()
-	fmt.Fprintln(w, "Benchmark\tBaseline CPU usage\tYour CPU usage\t% Difference")
-	fmt.Fprintln(w, "\t\t\tThe smaller the better")
-	for i, b := range baseBenchmarks {
-		fmt.Fprintln(w, b.name, "\t", baseTimes[i].result, "%\t", newTimes[i].result, "%\t", baseTimes[i].result*100/newTimes[i].result, "%")
+	if lowerIsBetter {
+		fmt.Fprintln(w, "Benchmark\tBaseline result\tYour result\tSpeed-up\t")
+		fmt.Fprintln(w, "\t(ns/1000 turns)\t(ns/1000 turns)\tThe bigger the better\t")
+	} else {
+		fmt.Fprintln(w, "Benchmark\tBaseline CPU usage\tYour CPU usage\t% Difference\t")
+		fmt.Fprintln(w, "\t\t\tThe smaller the better\t")
+	}
+	for _, c := range comparisons {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.0f%%\t%s\n", c.name, describe(c.base, unit), describe(c.ours, unit),
+			100*c.base.mean/c.ours.mean, verdict(c, lowerIsBetter))
+	}
+	for _, m := range missing {
+		fmt.Fprintln(w, m)
 	}
+}
+
+func printText(comparisons, cpuComparisons []comparison, missing, cpuMissing []string) {
+	fmt.Println()
+	fmt.Println("TIME RESULTS")
+	printComparisons(comparisons, missing, "", true)
+	fmt.Println()
+	fmt.Println("CPU USAGE RESULTS")
+	printComparisons(cpuComparisons, cpuMissing, "%", false)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	defer w.Flush()
 	fmt.Fprintln(w, "This is the percentage of the CPU that this job got. It's computed as (U + S) / E")
 	fmt.Fprintln(w, "Where")
 	fmt.Fprintln(w, "U\tTotal number of CPU-seconds that the process spent in user mode.")
 	fmt.Fprintln(w, "S\tTotal number of CPU-seconds that the process spent in kernel mode.")
 	fmt.Fprintln(w, "E\tElapsed real time")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Results are the mean ± the 95%% confidence interval, from n runs. Differences are tested with Welch's t-test at p < %g.\n", *alpha)
+	fmt.Fprintf(w, "A REGRESSION is a significant slow-down of more than %g%%.\n", *threshold)
+}
+
+// Flags for the comparison.
+var (
+	alpha     = flag.Float64("alpha", 0.05, "Specify the significance level below which a difference counts as real.")
+	threshold = flag.Float64("threshold", 5, "Specify how many percent slower than the baseline a benchmark may be before it counts as a regression.")
+	format    = flag.String("format", formatText, "Specify the output format: text, json or csv.")
+	history   = flag.String("history", "benchmark-history.jsonl", "Specify the file to add your results to after each comparison, or \"\" to not keep them.")
+	trend     = flag.Bool("trend", false, "Show how the benchmarks changed over the runs in the history, instead of comparing.")
+	binary    = flag.String("binary", "", "Specify the test binary your benchmarks ran in, to record the Go version it was built with in the history.")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: compare [-alpha A] [-threshold T] [-format F] [-history H] [-binary B] <baseline cpu> <your cpu> <baseline benchmarks> <your benchmarks>")
+	fmt.Fprintln(os.Stderr, "       compare -trend [-history H] [benchmark...]")
+	fmt.Fprintln(os.Stderr, "It exits with 1 if any benchmark regressed.")
+	flag.PrintDefaults()
 }
 
-func readBenchmarks(file []byte) []bench {
-	numberRegex, _ := regexp.Compile(`\d+`)
-	rowRegex, _ := regexp.Compile(`\d+x\d+x\d+-?\d*\s+\d+\s+\d+ ns/op`)
-	rows := rowRegex.FindAllString(string(file), -1)
-	benchmarks := make([]bench, len(rows))
-	for i, row := range rows {
-		fields := strings.Fields(row)
-		result, err := strconv.ParseInt(numberRegex.FindString(fields[2]), 10, 64)
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	read := func(path string) []byte {
+		data, err := ioutil.ReadFile(path)
 		check(err)
-		benchmarks[i] = bench{name: fields[0], result: result}
+		return data
+	}
+	if *trend {
+		if *history == "" {
+			usage()
+			os.Exit(2)
+		}
+		entries, err := readHistory(read(*history))
+		check(err)
+		printTrend(os.Stdout, entries, flag.Args())
+		return
+	}
+	if flag.NArg() != 4 || !validFormat(*format) {
+		usage()
+		os.Exit(2)
 	}
-	return benchmarks
-}
 
-//noinspection GoUnhandledErrorResult
-func analyseBenchmarks() {
-	baseBenchmarksFile, err := ioutil.ReadFile(os.Args[3])
+	base, err := readBenchmarks(read(flag.Arg(2)))
 	check(err)
-	newBenchmarksFile, err := ioutil.ReadFile(os.Args[4])
+	ours, err := readBenchmarks(read(flag.Arg(3)))
 	check(err)
-	baseBenchmarks := readBenchmarks(baseBenchmarksFile)
-	newBenchmarks := readBenchmarks(newBenchmarksFile)
-	if len(baseBenchmarks) != len(newBenchmarks) {
-		panic("Benchmark lengths don't match")
+	baseCpu, err := readCpuTimes(read(flag.Arg(0)), base.names)
+	check(err)
+	oursCpu, err := readCpuTimes(read(flag.Arg(1)), ours.names)
+	check(err)
+
+	if *history != "" {
+		check(appendHistory(*history, newHistoryEntry(ours, oursCpu, binaryGoVersion(*binary))))
 	}
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	defer w.Flush()
-	fmt.Fprintln(w, "Benchmark\tBaseline result\tYour result\t% Difference")
-	fmt.Fprintln(w, "\t(ns/1000 turns)\t(ns/1000 turns)\tThe bigger the better")
-	for i, b := range baseBenchmarks {
-		baselineResult := b.result
-		newResult := newBenchmarks[i].result
-		fmt.Fprintln(w, b.name, "\t", baselineResult, "\t", newResult, "\t", baselineResult*100/newResult, "%")
 
+	comparisons, missing := compare(base, ours)
+	cpuComparisons, cpuMissing := compare(baseCpu, oursCpu)
+	switch *format {
+	case formatText:
+		printText(comparisons, cpuComparisons, missing, cpuMissing)
+	case formatJSON:
+		check(writeJSON(os.Stdout, reports(comparisons, cpuComparisons), missing))
+	case formatCSV:
+		check(writeCSV(os.Stdout, reports(comparisons, cpuComparisons)))
+		for _, m := range missing {
+			fmt.Fprintln(os.Stderr, m)
+		}
 	}
-}
 
-func main() {
-	fmt.Println()
-	fmt.Println("TIME RESULTS")
-	analyseBenchmarks()
-	fmt.Println()
-	fmt.Println("CPU USAGE RESULTS")
-	analyseCpuTimes()
+	for _, c := range comparisons {
+		if c.regressed(*threshold) {
+			os.Exit(1)
+		}
+	}
 }
@@ -1,13 +1,22 @@
 #!/usr/bin/env bash
 
+# Benchmarks your solution and the baseline several times each, and compares them.
+# Usage: ./comparison/compare.sh [repetitions]
+# Flags for compare, such as -format json or -threshold 10, can be given in COMPARE_FLAGS.
+# It exits with 1 if any benchmark regressed, so it can be used to check a change before merging it.
+# The two take turns, so that anything else slowing the machine down affects both alike.
+# Your results are added to benchmark-history.jsonl each time, and 'make trend' shows how they changed from commit to commit.
+
+repetitions=${1:-5}
+
 rm -f your-time.txt
 touch your-time.txt
-rm -f your-out.txt
-touch your-out.txt
+rm -f your-out.json
+touch your-out.json
 rm -f base-time.txt
 touch base-time.txt
-rm -f base-out.txt
-touch base-out.txt
+rm -f base-out.json
+touch base-out.json
 
 go test -c -o gameoflife.test
 
@@ -15,14 +24,17 @@ echo "Benchmarking..."
 
 benchtime=10x
 
-#for b in 128x128x2 128x128x4 128x128x8 512x512x2 512x512x4 512x512x8
-for b in 512x512x2 512x512x4 512x512x8
+for i in $(seq ${repetitions})
 do
-    echo ${b} on your solution
-    \time -f '%P' -o your-time.txt -a ./gameoflife.test -test.run XXX -test.bench /${b} -test.benchtime ${benchtime} >> your-out.txt
-    echo ${b} on baseline solution
-    \time -f '%P' -o base-time.txt -a ./baseline.test -test.run XXX -test.bench /${b} -test.benchtime ${benchtime} >> base-out.txt
+    #for b in 128x128x2 128x128x4 128x128x8 512x512x2 512x512x4 512x512x8
+    for b in 512x512x2 512x512x4 512x512x8
+    do
+        echo ${b} on your solution, run ${i} of ${repetitions}
+        \time -f "${b} %P" -o your-time.txt -a ./gameoflife.test -test.v -test.run XXX -test.bench /${b} -test.benchtime ${benchtime} | go tool test2json -t >> your-out.json
+        echo ${b} on baseline solution, run ${i} of ${repetitions}
+        \time -f "${b} %P" -o base-time.txt -a ./baseline.test -test.v -test.run XXX -test.bench /${b} -test.benchtime ${benchtime} | go tool test2json -t >> base-out.json
+    done
 done
 
-go build comparison/compare.go
-./compare base-time.txt your-time.txt base-out.txt your-out.txt
\ No newline at end of file
+go build -o compare ./comparison
+./compare ${COMPARE_FLAGS} -binary gameoflife.test base-time.txt your-time.txt base-out.json your-out.json
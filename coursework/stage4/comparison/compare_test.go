@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatistics(t *testing.T) {
+	// Values from a table of Student's t distribution.
+	assert.InDelta(t, 2.776, studentQuantile(0.975, 4), 0.001)
+	assert.InDelta(t, 2.228, studentQuantile(0.975, 10), 0.001)
+	assert.InDelta(t, 1.960, studentQuantile(0.975, 100000), 0.001)
+	assert.InDelta(t, 0.5, studentCDF(0, 3), 1e-9)
+
+	s := summarise([]float64{10, 12, 14, 16, 18})
+	assert.Equal(t, 5, s.n)
+	assert.InDelta(t, 14, s.mean, 1e-9)
+	assert.InDelta(t, 3.162, s.sd, 0.001)
+	assert.InDelta(t, 3.926, s.ci, 0.001)
+
+	// The same numbers shifted a little are not a significant difference, but shifted a lot they are.
+	assert.InDelta(t, 0.63, welch(s, summarise([]float64{11, 13, 15, 17, 19})), 0.01)
+	assert.True(t, welch(s, summarise([]float64{30, 32, 34, 36, 38})) < 0.001)
+	assert.Equal(t, 1.0, welch(s, summarise([]float64{100})), "one run says nothing")
+}
+
+func TestReadBenchmarks(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		r, err := readBenchmarks([]byte("goos: linux\ncpu: AMD EPYC 7763 64-Core Processor                \nBenchmark/512x512x2-12 \t 10\t 11246748358 ns/op\nBenchmark/512x512x2-12 \t 10\t 11000000000 ns/op\t 12 B/op\nPASS\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"512x512x2"}, r.names)
+		assert.Equal(t, []float64{11246748358, 11000000000}, r.values["512x512x2"])
+		assert.Equal(t, 12, r.gomaxprocs)
+		assert.Equal(t, "AMD EPYC 7763 64-Core Processor", r.cpu)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		// The name and the result of a benchmark are in different events.
+		r, err := readBenchmarks([]byte(`{"Action":"start"}
+{"Action":"output","Output":"Benchmark/64x64x2         \t"}
+{"Action":"output","Output":"       3\t 147983854 ns/op\n"}
+{"Action":"output","Output":"Benchmark/64x64x4         \t       3\t 187046746 ns/op\n"}
+{"Action":"pass"}
+`))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"64x64x2", "64x64x4"}, r.names)
+		assert.Equal(t, []float64{147983854}, r.values["64x64x2"])
+		assert.Equal(t, 1, r.gomaxprocs, "no suffix means GOMAXPROCS=1")
+		assert.Equal(t, "", r.cpu)
+	})
+
+	t.Run("different GOMAXPROCS", func(t *testing.T) {
+		_, err := readBenchmarks([]byte("Benchmark/64x64x2-4 \t 3\t 147983854 ns/op\nBenchmark/64x64x2-8 \t 3\t 107983854 ns/op\n"))
+		assert.EqualError(t, err, "Benchmark/64x64x2-8 ran with GOMAXPROCS=8, but the benchmarks before it with GOMAXPROCS=4")
+	})
+
+	t.Run("nothing", func(t *testing.T) {
+		_, err := readBenchmarks([]byte("PASS\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestReadCpuTimes(t *testing.T) {
+	r, err := readCpuTimes([]byte("512x512x2 187%\n512x512x4 320%\n512x512x2 190%\n"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{187, 190}, r.values["512x512x2"])
+
+	// Older files only have the percentages, in the order the benchmarks ran.
+	r, err = readCpuTimes([]byte("187%\n320%\n581%\n"), []string{"512x512x2", "512x512x4", "512x512x8"})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{581}, r.values["512x512x8"])
+}
+
+func TestCompare(t *testing.T) {
+	base, ours := newResults(), newResults()
+	for _, v := range []float64{100, 102, 98, 101, 99} {
+		base.add("a", v)
+		base.add("b", v)
+		ours.add("a", v*1.5)
+	}
+	ours.add("c", 1)
+
+	comparisons, missing := compare(base, ours)
+	if assert.Len(t, comparisons, 1) {
+		assert.True(t, comparisons[0].significant())
+		assert.Contains(t, verdict(comparisons[0], true), "REGRESSION")
+		assert.Contains(t, verdict(comparisons[0], false), "improvement")
+	}
+	assert.Equal(t, []string{"b is only in the baseline", "c is only in your results"}, missing)
+}
+
+func TestRegressed(t *testing.T) {
+	base := summarise([]float64{100, 102, 98, 101, 99})
+	slower := comparison{base: base, ours: summarise([]float64{104, 106, 102, 105, 103})}
+	slower.p = welch(slower.base, slower.ours)
+	assert.True(t, slower.significant())
+	assert.False(t, slower.regressed(5), "4% slower is within the threshold")
+	assert.True(t, slower.regressed(3))
+
+	// Runs that vary this much cannot show a 10% slow-down is real.
+	noisy := comparison{base: base, ours: summarise([]float64{60, 160, 90, 130, 110})}
+	noisy.p = welch(noisy.base, noisy.ours)
+	assert.False(t, noisy.regressed(5))
+
+	single := comparison{base: summarise([]float64{100}), ours: summarise([]float64{110})}
+	single.p = welch(single.base, single.ours)
+	assert.True(t, single.regressed(5), "with one run of each, the slow-down alone decides")
+}
+
+func TestReports(t *testing.T) {
+	base, ours := newResults(), newResults()
+	base.add("512x512x2", 200)
+	ours.add("512x512x2", 100)
+	base.add("512x512x8", 100)
+	ours.add("512x512x8", 200)
+	cpu := newResults()
+	cpu.add("512x512x8", 400)
+	comparisons, _ := compare(base, ours)
+	cpuComparisons, _ := compare(cpu, cpu)
+	r := reports(comparisons, cpuComparisons)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, writeCSV(&buffer, r))
+	assert.Equal(t, "benchmark,baseline_ns_per_op,baseline_ci,baseline_runs,ns_per_op,ci,runs,baseline_cpu_percent,cpu_percent,speed_up,p,regression\n"+
+		"512x512x2,200,0,1,100,0,1,0,0,2,1,false\n"+
+		"512x512x8,100,0,1,200,0,1,400,400,0.5,1,true\n", buffer.String())
+
+	buffer.Reset()
+	assert.NoError(t, writeJSON(&buffer, r, []string{"64x64x2 is only in the baseline"}))
+	var decoded struct {
+		Benchmarks []report
+		Missing    []string
+	}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+	assert.Equal(t, r, decoded.Benchmarks)
+	assert.Equal(t, []string{"64x64x2 is only in the baseline"}, decoded.Missing)
+	assert.Contains(t, buffer.String(), `"speed_up": 2,`)
+
+	buffer.Reset()
+	assert.NoError(t, writeJSON(&buffer, nil, nil))
+	assert.JSONEq(t, `{"benchmarks": [], "missing": []}`, buffer.String())
+}
+
+func TestHistory(t *testing.T) {
+	assert.Equal(t, "AMD EPYC 7763 64-Core Processor",
+		parseCpuModel([]byte("processor\t: 0\nvendor_id\t: AuthenticAMD\nmodel name\t: AMD EPYC 7763 64-Core Processor\n\nprocessor\t: 1\nmodel name\t: other\n")))
+	assert.Equal(t, "", parseCpuModel([]byte("processor\t: 0\n")))
+
+	ours, cpu := newResults(), newResults()
+	for _, v := range []float64{100, 102, 98, 101, 99} {
+		ours.add("512x512x8", v)
+	}
+	cpu.add("512x512x8", 600)
+	ours.gomaxprocs, ours.cpu = 8, "AMD EPYC 7763 64-Core Processor"
+	entry := newHistoryEntry(ours, cpu, "go1.21.0")
+	assert.Equal(t, summarise(ours.values["512x512x8"]), entry.Benchmarks[0].summary())
+	assert.Equal(t, 600.0, entry.Benchmarks[0].CPUPercent)
+	assert.Equal(t, "go1.21.0", entry.GoVersion)
+	assert.Equal(t, 8, entry.GOMAXPROCS, "what the benchmarks ran with, not compare")
+	assert.Equal(t, "AMD EPYC 7763 64-Core Processor", entry.CPU)
+
+	// The test binary is built with the same Go as compare's tests.
+	assert.Equal(t, runtime.Version(), binaryGoVersion(os.Args[0]))
+	assert.Equal(t, runtime.Version(), binaryGoVersion(filepath.Join(t.TempDir(), "missing")))
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	slower := entry
+	slower.Commit = "abc1234-dirty"
+	slower.GoVersion = "go9.9"
+	slower.GOMAXPROCS = 4
+	slower.Time = entry.Time.Add(time.Hour)
+	slower.Benchmarks = []historyResult{{Benchmark: "512x512x8", NsPerOp: 150, SD: 1.5, Runs: 5}, {Benchmark: "512x512x2", NsPerOp: 300, Runs: 1}}
+	assert.NoError(t, appendHistory(path, entry))
+	assert.NoError(t, appendHistory(path, slower))
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"), "one line for each run")
+	entries, err := readHistory(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []historyEntry{entry, slower}, entries)
+
+	var buffer bytes.Buffer
+	printTrend(&buffer, entries, nil)
+	trend := buffer.String()
+	assert.Contains(t, trend, "512x512x8   ▁█")
+	assert.Contains(t, trend, "abc1234-dirty")
+	assert.Contains(t, trend, "+50.0% REGRESSION")
+	assert.Contains(t, trend, "now go9.9, GOMAXPROCS=4")
+	assert.Contains(t, trend, "512x512x2   ▅")
+
+	buffer.Reset()
+	printTrend(&buffer, entries, []string{"64x64x2"})
+	assert.Contains(t, buffer.String(), "not in the history")
+
+	_, err = readHistory([]byte("{\"commit\": \"abc\"}\nnot json\n"))
+	assert.EqualError(t, err, "entry 2 of the history: invalid character 'o' in literal null (expecting 'u')")
+}
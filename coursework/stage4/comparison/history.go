@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// historyEntry is one run of compare in the history file, with what your solution got in every benchmark
+// and what it ran on, so that changes of machine are not mistaken for changes in the code.
+type historyEntry struct {
+	Time       time.Time       `json:"time"`
+	Commit     string          `json:"commit"`
+	GoVersion  string          `json:"go_version"`
+	GOMAXPROCS int             `json:"gomaxprocs"`
+	CPU        string          `json:"cpu"`
+	Benchmarks []historyResult `json:"benchmarks"`
+}
+
+// historyResult is how one benchmark did in a run, in ns/op. The CPU usage is 0 if it was not measured.
+type historyResult struct {
+	Benchmark  string  `json:"benchmark"`
+	NsPerOp    float64 `json:"ns_per_op"`
+	SD         float64 `json:"sd"`
+	Runs       int     `json:"runs"`
+	CPUPercent float64 `json:"cpu_percent"`
+}
+
+// summary turns the result back into the summary it was made from.
+func (r historyResult) summary() summary {
+	s := summary{n: r.Runs, mean: r.NsPerOp, sd: r.SD}
+	if s.n > 1 {
+		s.ci = studentQuantile(0.975, float64(s.n-1)) * s.sd / math.Sqrt(float64(s.n))
+	}
+	return s
+}
+
+// newHistoryEntry records your results and CPU usage, along with the commit and the machine they are from.
+// The GOMAXPROCS and CPU are the ones the benchmarks reported. If go test did not report the CPU,
+// it is taken from this machine, which compare.sh runs on too.
+func newHistoryEntry(ours, oursCpu *results, goVersion string) historyEntry {
+	entry := historyEntry{
+		Time:       time.Now().UTC().Truncate(time.Second),
+		Commit:     gitCommit(),
+		GoVersion:  goVersion,
+		GOMAXPROCS: ours.gomaxprocs,
+		CPU:        ours.cpu,
+	}
+	if entry.CPU == "" {
+		entry.CPU = cpuModel()
+	}
+	for _, name := range ours.names {
+		s := summarise(ours.values[name])
+		entry.Benchmarks = append(entry.Benchmarks, historyResult{
+			Benchmark:  name,
+			NsPerOp:    s.mean,
+			SD:         s.sd,
+			Runs:       s.n,
+			CPUPercent: summarise(oursCpu.values[name]).mean,
+		})
+	}
+	return entry
+}
+
+// gitCommit describes the commit being benchmarked, ending in -dirty if there are changes that are not committed.
+// It is empty outside of a git repository.
+func gitCommit() string {
+	out, err := exec.Command("git", "describe", "--always", "--dirty").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// binaryGoVersion reads the version of Go the test binary was built with. Without a binary, or if it cannot be read,
+// it is the version compare was built with, which compare.sh builds with the same go command.
+func binaryGoVersion(path string) string {
+	if path != "" {
+		if info, err := buildinfo.ReadFile(path); err == nil {
+			return info.GoVersion
+		}
+	}
+	return runtime.Version()
+}
+
+// cpuModel reads the model of the first CPU from /proc/cpuinfo, or returns "" where there is no such file.
+func cpuModel() string {
+	data, err := ioutil.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	return parseCpuModel(data)
+}
+
+func parseCpuModel(cpuinfo []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(cpuinfo))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if found && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// appendHistory adds the entry to the end of the history file as a line of JSON, creating the file if need be.
+func appendHistory(path string, entry historyEntry) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readHistory reads every entry in a history file, oldest first.
+func readHistory(data []byte) ([]historyEntry, error) {
+	var entries []historyEntry
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var entry historyEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("entry %d of the history: %v", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// sparkBlocks are the heights of a sparkline, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the values as a line of blocks, from the lowest value to the highest.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min, max = math.Min(min, v), math.Max(max, v)
+	}
+	var line strings.Builder
+	for _, v := range values {
+		i := len(sparkBlocks) / 2
+		if max > min {
+			i = int(math.Round((v - min) / (max - min) * float64(len(sparkBlocks)-1)))
+		}
+		line.WriteRune(sparkBlocks[i])
+	}
+	return line.String()
+}
+
+// environmentChanges says what is different about the machine between two entries, or returns "" if nothing is.
+func environmentChanges(previous, entry historyEntry) string {
+	var changes []string
+	if previous.GoVersion != entry.GoVersion {
+		changes = append(changes, entry.GoVersion)
+	}
+	if previous.GOMAXPROCS != entry.GOMAXPROCS {
+		changes = append(changes, fmt.Sprintf("GOMAXPROCS=%d", entry.GOMAXPROCS))
+	}
+	if previous.CPU != entry.CPU {
+		changes = append(changes, "CPU "+entry.CPU)
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return "now " + strings.Join(changes, ", ")
+}
+
+// printTrend shows each of the named benchmarks, or all of them if there are no names, run by run through the history.
+// Each run is compared with the one before it, so that a REGRESSION points at the commit that made the benchmark slower.
+func printTrend(w io.Writer, entries []historyEntry, names []string) {
+	if len(names) == 0 {
+		seen := make(map[string]bool)
+		for _, entry := range entries {
+			for _, r := range entry.Benchmarks {
+				if !seen[r.Benchmark] {
+					seen[r.Benchmark] = true
+					names = append(names, r.Benchmark)
+				}
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	defer tw.Flush()
+	for _, name := range names {
+		var runs []historyEntry
+		var results []historyResult
+		var means []float64
+		for _, entry := range entries {
+			for _, r := range entry.Benchmarks {
+				if r.Benchmark == name {
+					runs = append(runs, entry)
+					results = append(results, r)
+					means = append(means, r.NsPerOp)
+				}
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, sparkline(means))
+		if len(runs) == 0 {
+			fmt.Fprintln(tw, "\tnot in the history")
+			fmt.Fprintln(tw)
+			continue
+		}
+		fmt.Fprintln(tw, "\tWhen\tCommit\tResult (ns/1000 turns)\tChange")
+		for i, entry := range runs {
+			change, machine := "", ""
+			if i > 0 {
+				c := comparison{name: name, base: results[i-1].summary(), ours: results[i].summary()}
+				c.p = welch(c.base, c.ours)
+				change = fmt.Sprintf("%+.1f%% %s", 100*(c.ours.mean/c.base.mean-1), verdict(c, true))
+				machine = environmentChanges(runs[i-1], entry)
+			}
+			fmt.Fprintf(tw, "\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format("2006-01-02 15:04"), entry.Commit,
+				describe(results[i].summary(), ""), change, machine)
+		}
+		fmt.Fprintln(tw)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Output formats that can be selected with -format.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+func validFormat(format string) bool {
+	return format == formatText || format == formatJSON || format == formatCSV
+}
+
+// report is how one benchmark did, for the machine readable outputs.
+// Times are means in ns/op, and the CPU usage is 0 for a benchmark it was not measured for.
+type report struct {
+	Benchmark       string  `json:"benchmark"`
+	BaselineNsPerOp float64 `json:"baseline_ns_per_op"`
+	BaselineCI      float64 `json:"baseline_ci"`
+	BaselineRuns    int     `json:"baseline_runs"`
+	NsPerOp         float64 `json:"ns_per_op"`
+	CI              float64 `json:"ci"`
+	Runs            int     `json:"runs"`
+	BaselineCPU     float64 `json:"baseline_cpu_percent"`
+	CPU             float64 `json:"cpu_percent"`
+	SpeedUp         float64 `json:"speed_up"`
+	P               float64 `json:"p"`
+	Regression      bool    `json:"regression"`
+}
+
+// reports joins up the times and CPU usage of each benchmark by name.
+func reports(comparisons, cpuComparisons []comparison) []report {
+	cpu := make(map[string]comparison)
+	for _, c := range cpuComparisons {
+		cpu[c.name] = c
+	}
+	var reports []report
+	for _, c := range comparisons {
+		reports = append(reports, report{
+			Benchmark:       c.name,
+			BaselineNsPerOp: c.base.mean,
+			BaselineCI:      c.base.ci,
+			BaselineRuns:    c.base.n,
+			NsPerOp:         c.ours.mean,
+			CI:              c.ours.ci,
+			Runs:            c.ours.n,
+			BaselineCPU:     cpu[c.name].base.mean,
+			CPU:             cpu[c.name].ours.mean,
+			SpeedUp:         c.base.mean / c.ours.mean,
+			P:               c.p,
+			Regression:      c.regressed(*threshold),
+		})
+	}
+	return reports
+}
+
+// writeJSON writes the reports, and the benchmarks that are only in one of the baseline and your results.
+func writeJSON(w io.Writer, reports []report, missing []string) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Benchmarks []report `json:"benchmarks"`
+		Missing    []string `json:"missing"`
+	}{append([]report{}, reports...), append([]string{}, missing...)})
+}
+
+// writeCSV writes the reports with a header row, using the same names for the columns as the JSON.
+func writeCSV(w io.Writer, reports []report) error {
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"benchmark", "baseline_ns_per_op", "baseline_ci", "baseline_runs", "ns_per_op", "ci", "runs",
+		"baseline_cpu_percent", "cpu_percent", "speed_up", "p", "regression"})
+	number := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	for _, r := range reports {
+		_ = out.Write([]string{r.Benchmark, number(r.BaselineNsPerOp), number(r.BaselineCI), strconv.Itoa(r.BaselineRuns),
+			number(r.NsPerOp), number(r.CI), strconv.Itoa(r.Runs), number(r.BaselineCPU), number(r.CPU),
+			number(r.SpeedUp), number(r.P), strconv.FormatBool(r.Regression)})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,138 @@
+package main
+
+import "math"
+
+// summary describes a set of repeated measurements.
+type summary struct {
+	n    int
+	mean float64
+	sd   float64
+
+	// ci is the half width of the 95% confidence interval for the mean, so the true mean
+	// is within mean ± ci with 95% confidence. It is 0 if there is only one measurement.
+	ci float64
+}
+
+func summarise(values []float64) summary {
+	s := summary{n: len(values)}
+	if s.n == 0 {
+		return s
+	}
+	for _, v := range values {
+		s.mean += v
+	}
+	s.mean /= float64(s.n)
+	if s.n == 1 {
+		return s
+	}
+	for _, v := range values {
+		s.sd += (v - s.mean) * (v - s.mean)
+	}
+	s.sd = math.Sqrt(s.sd / float64(s.n-1))
+	s.ci = studentQuantile(0.975, float64(s.n-1)) * s.sd / math.Sqrt(float64(s.n))
+	return s
+}
+
+// relativeCI returns the confidence interval as a fraction of the mean.
+func (s summary) relativeCI() float64 {
+	if s.mean == 0 {
+		return 0
+	}
+	return s.ci / s.mean
+}
+
+// welch runs Welch's t-test on two sets of measurements, which does not assume they vary by the same amount.
+// It returns the probability of seeing a difference in means at least this big if there were no real difference.
+// With fewer than two measurements in either set there is nothing to go on, and it returns 1.
+func welch(a, b summary) float64 {
+	if a.n < 2 || b.n < 2 {
+		return 1
+	}
+	va, vb := a.sd*a.sd/float64(a.n), b.sd*b.sd/float64(b.n)
+	if va+vb == 0 {
+		if a.mean == b.mean {
+			return 1
+		}
+		return 0
+	}
+	t := (a.mean - b.mean) / math.Sqrt(va+vb)
+	df := (va + vb) * (va + vb) / (va*va/float64(a.n-1) + vb*vb/float64(b.n-1))
+	return 2 * (1 - studentCDF(math.Abs(t), df))
+}
+
+// studentCDF is the cumulative distribution function of Student's t distribution with df degrees of freedom.
+func studentCDF(t, df float64) float64 {
+	p := 0.5 * incompleteBeta(df/2, 0.5, df/(df+t*t))
+	if t > 0 {
+		return 1 - p
+	}
+	return p
+}
+
+// studentQuantile returns the t for which studentCDF(t, df) is p, for p above a half.
+func studentQuantile(p, df float64) float64 {
+	low, high := 0.0, 1.0
+	for studentCDF(high, df) < p {
+		high *= 2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if studentCDF(mid, df) < p {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
+
+// incompleteBeta is the regularised incomplete beta function I_x(a, b),
+// found with a continued fraction as in Numerical Recipes.
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges quickly only on one side of the mean, so use the symmetry I_x(a, b) = 1 - I_1-x(b, a).
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(a, b, x) / a
+	}
+	return 1 - front*betaFraction(b, a, 1-x)/b
+}
+
+func betaFraction(a, b, x float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1.0; m <= 300; m++ {
+		for _, aa := range []float64{
+			m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m)),
+			-(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1)),
+		} {
+			d = 1 + aa*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + aa/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < 1e-12 {
+			break
+		}
+	}
+	return h
+}
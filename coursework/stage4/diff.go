@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// maxDiffCells is how many of the differing cells a report lists before leaving the rest out.
+const maxDiffCells = 20
+
+// maxDiffPicture is the largest area, in cells, that a report draws.
+const maxDiffPicture = 64 * 64
+
+// Colours for the picture of a diff.
+var (
+	diffBoth  = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	diffOnlyA = color.RGBA{0xE0, 0x30, 0x30, 0xFF}
+	diffOnlyB = color.RGBA{0x30, 0x60, 0xE0, 0xFF}
+	diffDead  = color.RGBA{0x00, 0x00, 0x00, 0xFF}
+)
+
+// boardDiff describes how two boards differ.
+type boardDiff struct {
+	a, b [][]byte
+
+	// onlyA and onlyB are the cells alive in one board but not the other, in rows.
+	onlyA, onlyB []cell
+
+	// first is the first differing cell, going along the rows. Both its x and y are -1 if the boards are the same.
+	first cell
+
+	// sizes says how big each board is if they are not the same size. Only the area they share is compared.
+	sizes string
+}
+
+// golDiff compares two boards cell by cell.
+func golDiff(a, b [][]byte) boardDiff {
+	d := boardDiff{a: a, b: b, first: cell{x: -1, y: -1}}
+	if len(a) != len(b) || len(a) > 0 && len(a[0]) != len(b[0]) {
+		d.sizes = fmt.Sprintf("%dx%d and %dx%d", boardWidth(a), len(a), boardWidth(b), len(b))
+	}
+
+	for y := 0; y < len(a) && y < len(b); y++ {
+		for x := 0; x < len(a[y]) && x < len(b[y]); x++ {
+			aliveA, aliveB := a[y][x] != 0, b[y][x] != 0
+			if aliveA == aliveB {
+				continue
+			}
+			if d.first.y == -1 {
+				d.first = cell{x: x, y: y}
+			}
+			if aliveA {
+				d.onlyA = append(d.onlyA, cell{x: x, y: y})
+			} else {
+				d.onlyB = append(d.onlyB, cell{x: x, y: y})
+			}
+		}
+	}
+	return d
+}
+
+func boardWidth(world [][]byte) int {
+	if len(world) == 0 {
+		return 0
+	}
+	return len(world[0])
+}
+
+// same reports whether the boards are the same size and have the same cells alive.
+func (d boardDiff) same() bool {
+	return d.sizes == "" && d.first.y == -1
+}
+
+// report describes the differences in words, calling the boards by the given names,
+// and draws the part of the boards where they differ.
+func (d boardDiff) report(nameA, nameB string) string {
+	if d.same() {
+		return "The boards are the same.\n"
+	}
+
+	var report strings.Builder
+	if d.sizes != "" {
+		fmt.Fprintf(&report, "The boards are different sizes: %s.\n", d.sizes)
+	}
+	listCells := func(name string, cells []cell) {
+		fmt.Fprintf(&report, "Alive only in %s (%d):", name, len(cells))
+		for i, c := range cells {
+			if i == maxDiffCells {
+				fmt.Fprintf(&report, " and %d more", len(cells)-maxDiffCells)
+				break
+			}
+			fmt.Fprintf(&report, " (%d, %d)", c.x, c.y)
+		}
+		report.WriteString("\n")
+	}
+	listCells(nameA, d.onlyA)
+	listCells(nameB, d.onlyB)
+	if d.first.y == -1 {
+		return report.String()
+	}
+	fmt.Fprintf(&report, "First difference at row %d, column %d.\n", d.first.y, d.first.x)
+
+	// Draw the smallest rectangle holding every difference, with a cell of space around it.
+	area := boundingBox(append(append([]cell(nil), d.onlyA...), d.onlyB...))
+	area.min = cell{x: max(area.min.x-1, 0), y: max(area.min.y-1, 0)}
+	area.max = cell{x: min(area.max.x+1, min(boardWidth(d.a), boardWidth(d.b))-1), y: min(area.max.y+1, min(len(d.a), len(d.b))-1)}
+	if (area.max.x-area.min.x+1)*(area.max.y-area.min.y+1) > maxDiffPicture {
+		return report.String()
+	}
+	fmt.Fprintf(&report, "Rows %d to %d, columns %d to %d ('#' alive in both, 'A' only in %s, 'B' only in %s):\n",
+		area.min.y, area.max.y, area.min.x, area.max.x, nameA, nameB)
+	for y := area.min.y; y <= area.max.y; y++ {
+		for x := area.min.x; x <= area.max.x; x++ {
+			aliveA, aliveB := d.a[y][x] != 0, d.b[y][x] != 0
+			switch {
+			case aliveA && aliveB:
+				report.WriteByte('#')
+			case aliveA:
+				report.WriteByte('A')
+			case aliveB:
+				report.WriteByte('B')
+			default:
+				report.WriteByte('.')
+			}
+		}
+		report.WriteByte('\n')
+	}
+	return report.String()
+}
+
+// image draws the area the boards share as a png, with cells alive in both white,
+// cells alive only in the first board red and cells alive only in the second blue.
+func (d boardDiff) image() []byte {
+	width, height := min(boardWidth(d.a), boardWidth(d.b)), min(len(d.a), len(d.b))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			aliveA, aliveB := d.a[y][x] != 0, d.b[y][x] != 0
+			switch {
+			case aliveA && aliveB:
+				img.SetRGBA(x, y, diffBoth)
+			case aliveA:
+				img.SetRGBA(x, y, diffOnlyA)
+			case aliveB:
+				img.SetRGBA(x, y, diffOnlyB)
+			default:
+				img.SetRGBA(x, y, diffDead)
+			}
+		}
+	}
+	var buffer bytes.Buffer
+	check(png.Encode(&buffer, img))
+	return buffer.Bytes()
+}
+
+// diffCommand compares two saved boards, in any of the formats the io goroutine can read.
+// It is run with 'gameoflife diff [-png diff.png] a.pgm b.pgm' and exits with 1 if the boards differ.
+func diffCommand(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	pngPath := flags.String("png", "", "Specify a png file to draw the differences in.")
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		fmt.Println("Usage: gameoflife diff [-png diff.png] <board A> <board B>")
+		os.Exit(2)
+	}
+
+	var boards [2][][]byte
+	for i := range boards {
+		data, err := ioutil.ReadFile(flags.Arg(i))
+		if err == nil {
+			boards[i], err = decodeImage(data)
+		}
+		if err != nil {
+			fmt.Println(flags.Arg(i)+":", err)
+			os.Exit(2)
+		}
+	}
+
+	d := golDiff(boards[0], boards[1])
+	fmt.Print(d.report(flags.Arg(0), flags.Arg(1)))
+	if *pngPath != "" {
+		check(ioutil.WriteFile(*pngPath, d.image(), 0644))
+	}
+	if !d.same() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// worldOf makes a world for p with the given cells alive.
+func worldOf(p golParams, alive []cell) [][]byte {
+	world := make([][]byte, p.imageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.imageWidth)
+	}
+	for _, c := range alive {
+		world[c.y][c.x] = 0xFF
+	}
+	return world
+}
+
+// assertAlive checks that the expected cells are alive, and no others.
+// If not, it describes how the boards differ with golDiff instead of listing every cell.
+func assertAlive(t *testing.T, p golParams, expected, actual []cell, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	inside := true
+	for _, cells := range [][]cell{expected, actual} {
+		for _, c := range cells {
+			if c.x < 0 || c.x >= p.imageWidth || c.y < 0 || c.y >= p.imageHeight {
+				assert.Fail(t, fmt.Sprintf("Cell %d,%d is outside the %dx%d board", c.x, c.y, p.imageWidth, p.imageHeight), msgAndArgs...)
+				inside = false
+			}
+		}
+	}
+	if !inside {
+		return false
+	}
+	world := worldOf(p, actual)
+	if len(aliveCells(world)) != len(actual) {
+		return assert.Fail(t, "Some alive cells are listed more than once", msgAndArgs...)
+	}
+	d := golDiff(worldOf(p, expected), world)
+	if d.same() {
+		return true
+	}
+	return assert.Fail(t, "The alive cells are not as expected:\n"+d.report("expected", "actual"), msgAndArgs...)
+}
+
+func TestGolDiff(t *testing.T) {
+	a := [][]byte{
+		{0, 0xFF, 0, 0},
+		{0, 0xFF, 0, 0},
+		{0, 0xFF, 0, 0},
+	}
+	b := [][]byte{
+		{0, 0, 0, 0},
+		{0xFF, 0xFF, 0xFF, 0},
+		{0, 0, 0, 0},
+	}
+
+	t.Run("same", func(t *testing.T) {
+		d := golDiff(a, a)
+		assert.True(t, d.same())
+		assert.Equal(t, "The boards are the same.\n", d.report("a", "b"))
+	})
+
+	t.Run("different", func(t *testing.T) {
+		d := golDiff(a, b)
+		assert.False(t, d.same())
+		assert.Equal(t, []cell{{x: 1, y: 0}, {x: 1, y: 2}}, d.onlyA)
+		assert.Equal(t, []cell{{x: 0, y: 1}, {x: 2, y: 1}}, d.onlyB)
+		assert.Equal(t, cell{x: 1, y: 0}, d.first)
+		assert.Equal(t, ""+
+			"Alive only in a (2): (1, 0) (1, 2)\n"+
+			"Alive only in b (2): (0, 1) (2, 1)\n"+
+			"First difference at row 0, column 1.\n"+
+			"Rows 0 to 2, columns 0 to 3 ('#' alive in both, 'A' only in a, 'B' only in b):\n"+
+			".A..\n"+
+			"B#B.\n"+
+			".A..\n", d.report("a", "b"))
+	})
+
+	t.Run("different sizes", func(t *testing.T) {
+		d := golDiff(a, a[:2])
+		assert.False(t, d.same())
+		assert.True(t, strings.HasPrefix(d.report("a", "b"), "The boards are different sizes: 4x3 and 4x2.\n"))
+	})
+
+	t.Run("long lists", func(t *testing.T) {
+		p := golParams{imageWidth: 64, imageHeight: 64}
+		var all []cell
+		for x := 0; x < 64; x++ {
+			all = append(all, cell{x: x, y: 10})
+		}
+		report := golDiff(worldOf(p, all), worldOf(p, nil)).report("a", "b")
+		assert.Contains(t, report, "Alive only in a (64): (0, 10) (1, 10)")
+		assert.Contains(t, report, " and 44 more\n")
+	})
+
+	t.Run("image", func(t *testing.T) {
+		img, err := png.Decode(bytes.NewReader(golDiff(a, b).image()))
+		check(err)
+		assert.Equal(t, 4, img.Bounds().Dx())
+		assert.Equal(t, 3, img.Bounds().Dy())
+		colours := map[cell]color.RGBA{
+			{x: 1, y: 0}: diffOnlyA,
+			{x: 0, y: 1}: diffOnlyB,
+			{x: 1, y: 1}: diffBoth,
+			{x: 3, y: 2}: diffDead,
+		}
+		for c, colour := range colours {
+			assert.Equal(t, colour, color.RGBAModel.Convert(img.At(c.x, c.y)), "%v", c)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Event is anything the distributor reports on the events chan passed to gameOfLife.
+// Subscribers can switch on the type of each event to find out what happened.
+type Event interface {
+	fmt.Stringer
+	// GetTurn is the number of turns that had been completed when the event happened.
+	GetTurn() int
+}
+
+// State is the execution state of the distributor, as reported by StateChange.
+type State uint8
+
+const (
+	Paused State = iota
+	Executing
+	Quitting
+)
+
+func (s State) String() string {
+	switch s {
+	case Paused:
+		return "Paused"
+	case Executing:
+		return "Executing"
+	case Quitting:
+		return "Quitting"
+	}
+	return "Incorrect State"
+}
+
+// TurnComplete is sent once every worker has finished a turn.
+type TurnComplete struct {
+	turn int
+}
+
+// AliveCount reports how many cells were alive after a turn.
+type AliveCount struct {
+	turn int
+	n    int
+}
+
+// CellFlipped is sent for every cell that changes state during a turn.
+// The cells alive in the loaded image are sent as flipped at turn 0.
+type CellFlipped struct {
+	turn int
+	cell cell
+}
+
+// ImageSaved is sent once the io goroutine has finished writing an image.
+type ImageSaved struct {
+	path string
+	turn int
+}
+
+// StateChange is sent when the distributor is paused, resumed or about to quit.
+type StateChange struct {
+	turn  int
+	state State
+}
+
+func (e TurnComplete) GetTurn() int { return e.turn }
+func (e AliveCount) GetTurn() int   { return e.turn }
+func (e CellFlipped) GetTurn() int  { return e.turn }
+func (e ImageSaved) GetTurn() int   { return e.turn }
+func (e StateChange) GetTurn() int  { return e.turn }
+
+func (e TurnComplete) String() string {
+	return "Turn " + strconv.Itoa(e.turn) + " complete"
+}
+
+func (e AliveCount) String() string {
+	return "alive: " + strconv.Itoa(e.n)
+}
+
+func (e CellFlipped) String() string {
+	return "Cell " + strconv.Itoa(e.cell.x) + "," + strconv.Itoa(e.cell.y) + " flipped"
+}
+
+func (e ImageSaved) String() string {
+	return "File " + e.path + " output done!"
+}
+
+func (e StateChange) String() string {
+	return e.state.String()
+}
+
+// eventsBuffer is how many events the chan made for the live view holds before the distributor has to wait.
+const eventsBuffer = 1 << 16
+
+// sendEvent passes the event on to the events chan, if gameOfLife was given one.
+func sendEvent(d distributorChans, e Event) {
+	if d.events != nil {
+		d.events <- e
+	}
+}
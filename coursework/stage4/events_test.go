@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collectEvents returns a chan for gameOfLife and a chan that receives every event sent on it once it is closed.
+func collectEvents() (chan<- Event, <-chan []Event) {
+	events := make(chan Event)
+	collected := make(chan []Event, 1)
+	go func() {
+		var all []Event
+		for e := range events {
+			all = append(all, e)
+		}
+		collected <- all
+	}()
+	return events, collected
+}
+
+func TestEvents(t *testing.T) {
+	p := golParams{
+		turns:       10,
+		threads:     4,
+		imageWidth:  16,
+		imageHeight: 16,
+	}
+	worlds := referenceWorlds(p, 16)
+	cleanOutput(t)
+
+	t.Run("flipped cells", func(t *testing.T) {
+		events, collected := collectEvents()
+		alive := gameOfLife(p, nil, events)
+
+		world := make([][]byte, p.imageHeight)
+		for y := range world {
+			world[y] = make([]byte, p.imageWidth)
+		}
+		turns := 0
+		for _, e := range <-collected {
+			switch e := e.(type) {
+			case CellFlipped:
+				// Flips are sent before the turn they belong to is complete, and the loaded image at turn 0.
+				if e.turn != 0 || turns != 0 {
+					assert.Equal(t, turns+1, e.turn)
+				}
+				world[e.cell.y][e.cell.x] = ^world[e.cell.y][e.cell.x]
+			case TurnComplete:
+				turns++
+				assert.Equal(t, turns, e.turn)
+				assertAlive(t, p, aliveCells(worlds[turns]), aliveCells(world), "turn %d", turns)
+			}
+		}
+		assert.Equal(t, p.turns, turns)
+		assertAlive(t, p, aliveCells(world), alive)
+	})
+
+	t.Run("state changes and images", func(t *testing.T) {
+		events, collected := collectEvents()
+		runScript(p, []scriptStep{{2, "psp"}, {4, "q"}}, events)
+
+		var reported []Event
+		for _, e := range <-collected {
+			if _, ok := e.(CellFlipped); !ok {
+				reported = append(reported, e)
+			}
+		}
+		assert.Equal(t, []Event{
+			TurnComplete{1},
+			TurnComplete{2},
+			StateChange{2, Paused},
+			ImageSaved{"out/16x16_2.pgm", 2},
+			StateChange{2, Executing},
+			TurnComplete{3},
+			TurnComplete{4},
+			StateChange{4, Quitting},
+			ImageSaved{"out/16x16_4.pgm", 4},
+		}, reported)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		events, collected := collectEvents()
+		runScript(p, []scriptStep{{3, "r"}, {2, "q"}}, events)
+
+		world := make([][]byte, p.imageHeight)
+		for y := range world {
+			world[y] = make([]byte, p.imageWidth)
+		}
+		var turns []int
+		for _, e := range <-collected {
+			switch e := e.(type) {
+			case CellFlipped:
+				world[e.cell.y][e.cell.x] = ^world[e.cell.y][e.cell.x]
+			case TurnComplete:
+				turns = append(turns, e.turn)
+				assertAlive(t, p, aliveCells(worlds[e.turn]), aliveCells(world), "turn %d", e.turn)
+			}
+		}
+		assert.Equal(t, []int{1, 2, 3, 0, 1, 2}, turns)
+	})
+}
+
+func TestAliveCount(t *testing.T) {
+	p := golParams{
+		turns:         200,
+		threads:       6,
+		imageWidth:    64,
+		imageHeight:   64,
+		aliveInterval: time.Millisecond,
+	}
+	worlds := referenceWorlds(p, p.turns)
+	cleanOutput(t)
+
+	events, collected := collectEvents()
+	gameOfLife(p, nil, events)
+
+	reports := 0
+	for _, e := range <-collected {
+		if e, ok := e.(AliveCount); ok {
+			reports++
+			assert.Equal(t, len(aliveCells(worlds[e.turn])), e.n, "turn %d", e.turn)
+		}
+	}
+	assert.NotZero(t, reports)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+)
+
+// gifBuffer is how many frames may wait for the encoder before the distributor waits for it.
+const gifBuffer = 64
+
+// gifDelay is how long each frame is shown for, in hundredths of a second.
+const gifDelay = 10
+
+// gifPalette draws dead cells black and alive cells white, as in the pgm images.
+var gifPalette = color.Palette{color.Gray{Y: 0}, color.Gray{Y: 0xFF}}
+
+// gifFrame is a copy of the world, one byte per cell in rows, taken after the given turn.
+type gifFrame struct {
+	turn  int
+	cells []byte
+}
+
+// gifRecorder copies the world from the distributor and hands it to an encoder goroutine.
+// The distributor only waits for the encoder once gifBuffer frames are waiting, so no frame is left out.
+type gifRecorder struct {
+	p      golParams
+	every  int
+	frames chan gifFrame
+	done   chan bool
+}
+
+// startGif starts writing an animated GIF of the run to p.gifPath.
+// It returns nil if no GIF was asked for.
+func startGif(p golParams) *gifRecorder {
+	if p.gifPath == "" {
+		return nil
+	}
+	g := &gifRecorder{
+		p:      p,
+		every:  p.gifEvery,
+		frames: make(chan gifFrame, gifBuffer),
+		done:   make(chan bool),
+	}
+	if g.every < 1 {
+		g.every = 1
+	}
+	go encodeGif(p, g.frames, g.done)
+	return g
+}
+
+// turnComplete adds a frame for every g.every turns.
+func (g *gifRecorder) turnComplete(world [][]byte, turns int) {
+	if g == nil || turns%g.every != 0 {
+		return
+	}
+	frame := gifFrame{turn: turns, cells: make([]byte, 0, g.p.imageWidth*g.p.imageHeight)}
+	for y := range world {
+		frame.cells = append(frame.cells, world[y]...)
+	}
+	g.frames <- frame
+}
+
+// finish waits for the encoder to write the last frame.
+func (g *gifRecorder) finish() {
+	if g == nil {
+		return
+	}
+	close(g.frames)
+	<-g.done
+}
+
+// encodeGif draws the frames as they arrive and writes the GIF file once the last one has.
+// Every frame is held until then, at one byte per pixel.
+func encodeGif(p golParams, frames <-chan gifFrame, done chan<- bool) {
+	scale := p.gifScale
+	if scale < 1 {
+		scale = 1
+	}
+
+	bounds := image.Rect(0, 0, p.imageWidth*scale, p.imageHeight*scale)
+	// A loop count of 0 plays the animation forever.
+	animation := &gif.GIF{LoopCount: 0}
+	for frame := range frames {
+		img := image.NewPaletted(bounds, gifPalette)
+		for i, c := range frame.cells {
+			if c == 0 {
+				continue
+			}
+			x, y := i%p.imageWidth*scale, i/p.imageWidth*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[(y+dy)*img.Stride+x+dx] = 1
+				}
+			}
+		}
+		animation.Image = append(animation.Image, img)
+		animation.Delay = append(animation.Delay, gifDelay)
+	}
+
+	file, ioError := os.Create(p.gifPath)
+	check(ioError)
+	w := bufio.NewWriter(file)
+	if len(animation.Image) > 0 {
+		check(gif.EncodeAll(w, animation))
+	}
+	check(w.Flush())
+	check(file.Close())
+	fmt.Println("File", p.gifPath, "output done!")
+	done <- true
+}
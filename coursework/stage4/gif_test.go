@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGif(t *testing.T) {
+	p := golParams{
+		turns:       8,
+		threads:     4,
+		imageWidth:  16,
+		imageHeight: 16,
+		gifPath:     filepath.Join(t.TempDir(), "run.gif"),
+		gifEvery:    2,
+		gifScale:    3,
+	}
+	worlds := referenceWorlds(p, p.turns)
+	cleanOutput(t)
+
+	gameOfLife(p, nil, nil)
+
+	file, err := os.Open(p.gifPath)
+	check(err)
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	check(err)
+
+	// Frames for turns 0, 2, 4, 6 and 8.
+	assert.Len(t, animation.Image, 5)
+	assert.Equal(t, 0, animation.LoopCount)
+	for i, frame := range animation.Image {
+		turn := i * p.gifEvery
+		assert.Equal(t, p.imageWidth*p.gifScale, frame.Bounds().Dx())
+		assert.Equal(t, p.imageHeight*p.gifScale, frame.Bounds().Dy())
+		assert.Equal(t, gifDelay, animation.Delay[i])
+
+		for y := 0; y < frame.Bounds().Dy(); y++ {
+			for x := 0; x < frame.Bounds().Dx(); x++ {
+				r, _, _, _ := frame.At(x, y).RGBA()
+				want := worlds[turn][y/p.gifScale][x/p.gifScale] != 0
+				if !assert.Equal(t, want, r != 0, "turn %d pixel %d,%d", turn, x, y) {
+					return
+				}
+			}
+		}
+	}
+
+	// The final image is still written as a pgm.
+	_, err = os.Stat("out/16x16_8.pgm")
+	assert.NoError(t, err)
+}
+
+// TestGifEveryFrame runs for more turns than there is room for frames waiting for the encoder,
+// which must all still be in the GIF.
+func TestGifEveryFrame(t *testing.T) {
+	p := golParams{
+		turns:       4 * gifBuffer,
+		threads:     4,
+		imageWidth:  64,
+		imageHeight: 64,
+		gifPath:     filepath.Join(t.TempDir(), "run.gif"),
+		gifEvery:    1,
+		gifScale:    4,
+	}
+	cleanOutput(t)
+
+	gameOfLife(p, nil, nil)
+
+	file, err := os.Open(p.gifPath)
+	check(err)
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	check(err)
+	assert.Len(t, animation.Image, p.turns+1)
+}
@@ -7,8 +7,16 @@ import (
 	"time"
 )
 
+// turnRates are the speeds, in turns per second, that '+' and '-' step through.
+// Stepping above the last rate removes the limit.
+var turnRates = []int{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// defaultAliveInterval is how often the number of alive cells is reported, unless golParams says otherwise.
+const defaultAliveInterval = 2 * time.Second
+
 // distributor divides the work between workers and interacts with other goroutines.
 func distributor(p golParams, d distributorChans, alive chan []cell) {
+	profiles := startProfiles(p)
 
 	// Create the 2D slice to store the world.
 	world := make([][]byte, p.imageHeight)
@@ -31,154 +39,361 @@ func distributor(p golParams, d distributorChans, alive chan []cell) {
 		}
 	}
 
-	workerHeight := p.imageHeight / p.threads
-	bigWorkers := p.imageHeight % p.threads
-
-	// Send the section of the image to workers byte by byte, in rows.
-	startY := 0
-	workerHeight++
-	for i := 0; i < p.threads; i++ {
-		if i == bigWorkers {
-			workerHeight--
+	// Keep a copy of the loaded image so that 'r' can go back to it.
+	initial := make([][]byte, p.imageHeight)
+	initialAlive := 0
+	for y := range initial {
+		initial[y] = append([]byte(nil), world[y]...)
+		for x := range initial[y] {
+			if initial[y][x] != 0 {
+				initialAlive++
+				sendEvent(d, CellFlipped{0, cell{x: x, y: y}})
+			}
 		}
-		for yd := -1; yd <= workerHeight; yd++ {
-			y := (startY + yd + p.imageHeight) % p.imageHeight
-			for x := 0; x < p.imageWidth; x++ {
-				d.workerVals[i] <- world[y][x]
+	}
+	// aliveCount is the number of alive cells after the last completed turn.
+	aliveCount := initialAlive
+	golMetrics.startRun(aliveCount)
+
+	// The rows each worker has, which change when they are rebalanced.
+	// balanceCompute adds up the time each worker has spent computing since the last rebalance.
+	sections := staticSections(p)
+	balanceCompute := make([]time.Duration, p.threads)
+	// The timeline starts before the workers are sent the world, so that their first wait for a turn is on it.
+	timing := startTiming(p)
+	sendWorld(p, d, world, sections)
+
+	// Frames for the GIF are encoded in the background while the workers carry on.
+	gifs := startGif(p)
+	gifs.turnComplete(world, 0)
+	stats := startStats(p)
+	stats.turnComplete(world, 0, aliveCount, 0, 0)
+	ships := startShips(p)
+	ships.turnComplete(world, 0)
+	recording := startRecording(p)
+	recording.keyframe(world, 0)
+
+	turns := 0
+
+	// Recent turns are remembered so that 'b' and 'f' can step through them while paused.
+	// workerTurns is the turn the workers are on, which is ahead of turns after stepping back.
+	past := newHistory(p)
+	workerTurns := 0
+
+	// travel flips the cells that changed in the given turn, which takes the world back a turn or forward again.
+	travel := func(turn, to int) {
+		for _, flipped := range past.flips(turn) {
+			for _, c := range flipped {
+				world[c.y][c.x] = ^world[c.y][c.x]
+				sendEvent(d, CellFlipped{to, c})
 			}
 		}
-		startY += workerHeight
+		turns = to
+		aliveCount = len(aliveCells(world))
+		sendEvent(d, TurnComplete{turns})
+		fmt.Println("Turn", turns)
 	}
 
-	pause := false
-	terminate := false
-
-	// 2b - Print alive cells every 2 seconds
-	ticker := time.NewTicker(2 * time.Second)
-	go func() {
-		for {
-			<-ticker.C
-			if pause == false {
-				for i := 0; i < p.threads; i++ {
-					d.workerCommands[i] <- workerSendAlive
-				}
-				totalAlive := 0
-				for i := 0; i < p.threads; i++ {
-					totalAlive += <-d.aliveWorkers
-				}
-				fmt.Println("alive:", totalAlive)
+	// branch starts a new run from the turn being shown, if it is earlier than the turn the workers are on.
+	// The turns after it are forgotten.
+	// The heat maps, statistics, GIF and ship tracker cannot forget turns, so while any of them is on
+	// the world goes forward again to the workers' turn instead.
+	branch := func() {
+		if turns == workerTurns {
+			return
+		}
+		if p.heatMap || p.statsEvery > 0 || p.gifPath != "" || p.trackShips {
+			fmt.Println("Cannot branch while heat maps, statistics, a GIF or ships are being recorded, so carrying on from turn", workerTurns)
+			for turns < workerTurns {
+				travel(turns+1, turns+1)
 			}
+			return
 		}
+		fmt.Println("Branching from turn", turns)
+		past.truncate(turns)
+		sendWorld(p, d, world, sections)
+		workerTurns = turns
+		recording.keyframe(world, turns)
+	}
+
+	// completeTurn has the workers calculate the next turn and passes the changes on to everything following the run.
+	completeTurn := func() {
+		branch()
+		turns++
+		workerTurns = turns
+		summary := nextTurn(p, d, world, turns)
+		aliveCount = summary.alive
+		past.add(turns, summary.flipped)
+		recording.turnComplete(turns, summary.flipped)
+		gifs.turnComplete(world, turns)
+		stats.turnComplete(world, turns, summary.alive, summary.births, summary.deaths)
+		ships.turnComplete(world, turns)
+		timing.turnComplete(turns, summary.started, summary.finished, summary.timings)
+
+		for i, t := range summary.timings {
+			balanceCompute[i] += t.compute
+		}
+		if p.rebalanceEvery > 0 && turns%p.rebalanceEvery == 0 {
+			balanced := p.test.chooseSections(sections, balanceCompute)
+			moveRows(p, d, sections, balanced)
+			sections = balanced
+			balanceCompute = make([]time.Duration, p.threads)
+		}
+	}
+
+	paused := false
+	showAlive := true
+	pace := newPacer(p.test.clock)
+	defer pace.stop()
 
-	}()
+	// 2b - Print alive cells every 2 seconds
+	ticks, stopTicker := p.test.clock.NewTicker(p.aliveInterval)
+	defer stopTicker()
 
 	// Calculate the new state of Game of Life after the given number of turns.
 Turns:
-	for turns := 0; turns < p.turns && terminate == false; {
-		// fmt.Println(turns)
-		//Key press handler
+	for turns < p.turns {
+		// Turns are only due while executing.
+		var due <-chan time.Time
+		if !paused {
+			due = pace.due()
+		}
+
+		// Key presses are handled before anything else that is ready.
+		var key rune
 		select {
-		case key := <-d.key:
-			switch key {
-			case 's':
-				fmt.Println("Make current PGM")
-				for i := 0; i < p.threads; i++ {
-					d.workerCommands[i] <- workerCurentPGM
+		case key = <-d.key:
+		default:
+			select {
+			case key = <-d.key:
+
+			case <-ticks:
+				if !paused && showAlive {
+					fmt.Println("Turn", turns, "alive:", aliveCount)
+					sendEvent(d, AliveCount{turns, aliveCount})
 				}
-				//Receive world from workers
-				startY = 0
-				workerHeight++
-				for i := 0; i < p.threads; i++ {
-					if i == bigWorkers {
-						workerHeight--
-					}
-					for yd := 0; yd < workerHeight; yd++ {
-						y := startY + yd
-						for x := 0; x < p.imageWidth; x++ {
-							world[y][x] = <-d.workerVals[i]
-						}
-					}
-					startY += workerHeight
+				continue
+
+			case <-due:
+				pace.turnStarted()
+				completeTurn()
+				if p.test.afterTurn != nil {
+					p.test.afterTurn(turns)
 				}
-				generatePGM(p, d, world)
+				continue
+			}
+		}
 
-			case 'p':
+		//Key press handler
+		switch key {
+		case 's':
+			fmt.Println("Make current PGM")
+			generatePGM(p, d, world, turns)
+			generateHeatMap(p, d, turns)
+
+		case 'p':
+			paused = !paused
+			if paused {
 				fmt.Println("Paused")
-				pause = true
-				var resume rune
-				for resume != 'p' {
-					resume = <-d.key
-				}
-				pause = false
+				sendEvent(d, StateChange{turns, Paused})
+			} else {
 				fmt.Println("Continuing")
+				sendEvent(d, StateChange{turns, Executing})
+			}
 
-			case 'q':
-				fmt.Println("Terminate and generate PGM")
-				break Turns
+		case 'n':
+			if paused {
+				completeTurn()
+				fmt.Println("Turn", turns)
+				if p.test.afterTurn != nil {
+					p.test.afterTurn(turns)
+				}
 			}
-		default:
-			turns++
-			for i := 0; i < p.threads; i++ {
-				d.workerNextTurns[i] <- 0
+
+		case 'b':
+			if paused && past.oldest() >= 0 && turns > past.oldest() {
+				travel(turns, turns-1)
+			}
+
+		case 'f':
+			if paused && turns < workerTurns {
+				travel(turns+1, turns+1)
 			}
+
+		case '+':
+			if pace.rate < len(turnRates) {
+				pace.setRate(pace.rate + 1)
+			}
+			printRate(pace.rate)
+
+		case '-':
+			if pace.rate > 0 {
+				pace.setRate(pace.rate - 1)
+			}
+			printRate(pace.rate)
+
+		case 'r':
+			fmt.Println("Reset to the loaded image")
+			for y := range world {
+				for x := range world[y] {
+					if world[y][x] != initial[y][x] {
+						sendEvent(d, CellFlipped{0, cell{x: x, y: y}})
+					}
+				}
+				copy(world[y], initial[y])
+			}
+			sendWorld(p, d, world, sections)
+			turns = 0
+			workerTurns = 0
+			sendEvent(d, TurnComplete{turns})
+			past.clear()
+			recording.keyframe(world, 0)
+			aliveCount = initialAlive
+			golMetrics.startRun(aliveCount)
+			gifs.turnComplete(world, 0)
+			stats.reset()
+			stats.turnComplete(world, 0, aliveCount, 0, 0)
+			ships = startShips(p)
+			ships.turnComplete(world, 0)
+
+		case 'c':
+			profiles.toggleCPU(turns)
+
+		case 'k':
+			showAlive = !showAlive
+			if showAlive {
+				fmt.Println("Alive cell reports on")
+			} else {
+				fmt.Println("Alive cell reports off")
+			}
+
+		case 'q':
+			fmt.Println("Terminate and generate PGM")
+			break Turns
 		}
 	}
 
+	sendEvent(d, StateChange{turns, Quitting})
+	generateHeatMap(p, d, turns)
 	for i := 0; i < p.threads; i++ {
 		d.workerCommands[i] <- workerQuit
 	}
 
-	//Receive world from workers
-	startY = 0
-	workerHeight++
-	for i := 0; i < p.threads; i++ {
-		if i == bigWorkers {
-			workerHeight--
-		}
-		for yd := 0; yd < workerHeight; yd++ {
-			y := startY + yd
-			for x := 0; x < p.imageWidth; x++ {
-				world[y][x] = <-d.workerVals[i]
-			}
-		}
-		startY += workerHeight
+	//Send world to pgm one byte at a time
+	generatePGM(p, d, world, turns)
+	gifs.finish()
+	stats.finish()
+	recording.finish()
+	profiles.finish()
+	timing.finish()
+
+	// The coordinates of cells that are still alive after p.turns are done.
+	finalAlive := aliveCells(world)
+
+	// Nothing else will be sent, so subscribers can stop listening.
+	if d.events != nil {
+		close(d.events)
 	}
 
-	//Send world to pgm one byte at a time
-	generatePGM(p, d, world)
+	// Return the coordinates of cells that are still alive.
+	alive <- finalAlive
+}
+
+// printRate prints the speed limit selected with '+' and '-'.
+func printRate(rate int) {
+	if rate == len(turnRates) {
+		fmt.Println("Speed: unlimited")
+	} else {
+		fmt.Println("Speed:", turnRates[rate], "turns/s")
+	}
+}
 
-	// Create an empty slice to store coordinates of cells that are still alive after p.turns are done.
-	var finalAlive []cell
+// turnSummary describes the changes made in a turn.
+type turnSummary struct {
+	alive  int
+	births int
+	deaths int
 
-	// Go through the world and append the cells that are still alive.
-	for y := 0; y < p.imageHeight; y++ {
-		for x := 0; x < p.imageWidth; x++ {
-			if world[y][x] != 0 {
-				finalAlive = append(finalAlive, cell{x: x, y: y})
+	// flipped holds the cells flipped by each worker.
+	flipped [][]cell
+
+	// started is when the turn was sent to the workers, and finished when the last of them reported back.
+	// timings holds how each worker spent the turn.
+	started, finished time.Time
+	timings           []workerTiming
+}
+
+// nextTurn asks every worker to calculate one more turn and waits until they have all finished it.
+// The cells flipped by the workers are applied to the distributor's copy of the world.
+func nextTurn(p golParams, d distributorChans, world [][]uint8, turns int) turnSummary {
+	summary := turnSummary{started: time.Now(), timings: make([]workerTiming, p.threads)}
+	for i := 0; i < p.threads; i++ {
+		d.workerNextTurns[i] <- 0
+	}
+	for i := 0; i < p.threads; i++ {
+		report := <-d.reports
+		summary.timings[report.worker] = report.timing
+		for _, c := range report.flipped {
+			world[c.y][c.x] = ^world[c.y][c.x]
+			if world[c.y][c.x] != 0 {
+				summary.births++
 			}
+			sendEvent(d, CellFlipped{turns, c})
 		}
+		summary.flipped = append(summary.flipped, report.flipped)
+		summary.deaths += len(report.flipped)
+		summary.alive += report.alive
 	}
+	summary.finished = time.Now()
+	summary.deaths -= summary.births
+	golMetrics.turnComplete(summary.alive)
+	sendEvent(d, TurnComplete{turns})
+	return summary
+}
 
-	// Make sure that the Io has finished any output before exiting.
-	d.io.command <- ioCheckIdle
-	<-d.io.idle
-
-	// Return the coordinates of cells that are still alive.
-	alive <- finalAlive
+// sendWorld loads each worker with its section of the world, including the halo rows above and below it.
+func sendWorld(p golParams, d distributorChans, world [][]uint8, sections sections) {
+	// Send the section of the image to workers byte by byte, in rows.
+	for i := 0; i < p.threads; i++ {
+		startY, workerHeight := sections[i], sections.height(i)
+		d.workerCommands[i] <- workerLoad
+		for yd := -1; yd <= workerHeight; yd++ {
+			y := (startY + yd + p.imageHeight) % p.imageHeight
+			// In a bounded universe there is nothing above the top row or below the bottom one.
+			outside := p.bounded && (startY+yd < 0 || startY+yd >= p.imageHeight)
+			for x := 0; x < p.imageWidth; x++ {
+				if outside {
+					d.workerVals[i] <- 0
+				} else {
+					d.workerVals[i] <- world[y][x]
+				}
+			}
+		}
+	}
 }
 
-func generatePGM(p golParams, d distributorChans, world [][]uint8) {
+// generatePGM sends the world to the io goroutine, to be saved under the number of turns completed.
+func generatePGM(p golParams, d distributorChans, world [][]uint8, turns int) {
+	filename := strings.Join([]string{strconv.Itoa(p.imageWidth), strconv.Itoa(p.imageHeight)}, "x") + "_" + strconv.Itoa(turns)
 	d.io.command <- ioOutput
-	d.io.filename <- strings.Join([]string{strconv.Itoa(p.imageWidth), strconv.Itoa(p.imageHeight)}, "x") + "_" + strconv.Itoa(p.turns)
+	d.io.filename <- filename
 
 	for y := 0; y < p.imageHeight; y++ {
 		for x := 0; x < p.imageWidth; x++ {
 			d.io.outputVal <- world[y][x]
 		}
 	}
+
+	// Make sure that the Io has finished the output before reporting it.
+	d.io.command <- ioCheckIdle
+	<-d.io.idle
+	sendEvent(d, ImageSaved{"out/" + filename + "." + outFormat(p), turns})
 }
 
-func worker(p golParams, val, topHalo, bottomHalo, nextTurn chan uint8, alive chan int, commandChan chan workerCommand, height int, num int) {
+// worker calculates the turns for the rows startY to startY+height-1 of the world.
+// After each turn it exchanges halos with its neighbours and reports the cells it flipped to the distributor.
+// When the distributor rebalances, its rows change to those it is told to take from or give to its neighbours.
+func worker(p golParams, val chan uint8, halos workerHalos, nextTurn chan uint8, reports chan workerReport, heatMaps chan workerHeatMap, commandChan chan workerCommand, moves <-chan rowMove, startY, height int, num int) {
 
 	// Create the 2D slice to store the section of the world.
 	world := make([][]byte, height+2)
@@ -191,33 +406,61 @@ func worker(p golParams, val, topHalo, bottomHalo, nextTurn chan uint8, alive ch
 		tempWorld[i] = make([]byte, p.imageWidth)
 	}
 
-	// Receive the section of the image byte by byte, in rows.
-	for y := 0; y < height+2; y++ {
-		for x := 0; x < p.imageWidth; x++ {
-			world[y][x] = <-val
+	// idleSince is when the worker last finished something the distributor asked for.
+	// The time from then until the next turn is spent waiting on the chans with the distributor.
+	idleSince := time.Now()
+
+	// Heat map counts for the cells in the section, only kept if they were asked for.
+	var aliveTurns, flips [][]uint32
+	if p.heatMap {
+		aliveTurns = make([][]uint32, height)
+		flips = make([][]uint32, height)
+		for i := range aliveTurns {
+			aliveTurns[i] = make([]uint32, p.imageWidth)
+			flips[i] = make([]uint32, p.imageWidth)
 		}
 	}
 
-	numAlive := 0
-
 Turns:
 	for {
 		select {
 		case command := <-commandChan:
 			switch command {
-			case workerCurentPGM:
-				for y := 1; y <= height; y++ {
+			case workerQuit:
+				break Turns
+			case workerLoad:
+				// Receive the section of the image byte by byte, in rows.
+				for y := 0; y < height+2; y++ {
 					for x := 0; x < p.imageWidth; x++ {
-						val <- world[y][x]
+						world[y][x] = <-val
 					}
 				}
-			case workerQuit:
-				break Turns
-			case workerSendAlive:
-				alive <- numAlive
+				// A new image starts the counts again.
+				for y := range aliveTurns {
+					for x := range aliveTurns[y] {
+						aliveTurns[y][x] = 0
+						flips[y][x] = 0
+					}
+				}
+			case workerHeat:
+				heatMap := workerHeatMap{startY: startY}
+				for y := range aliveTurns {
+					heatMap.aliveTurns = append(heatMap.aliveTurns, append([]uint32(nil), aliveTurns[y]...))
+					heatMap.flips = append(heatMap.flips, append([]uint32(nil), flips[y]...))
+				}
+				heatMaps <- heatMap
+			case workerRebalance:
+				moved := section{startY: startY, height: height, world: world, aliveTurns: aliveTurns, flips: flips}.move(p, halos, <-moves)
+				startY, height, world, aliveTurns, flips = moved.startY, moved.height, moved.world, moved.aliveTurns, moved.flips
+				tempWorld = make([][]byte, height+2)
+				for i := range tempWorld {
+					tempWorld[i] = make([]byte, p.imageWidth)
+				}
 			}
+			idleSince = time.Now()
 		case <-nextTurn:
-			numAlive = 0
+			computeStart := time.Now()
+			numAlive := 0
 
 			for y := 1; y <= height; y++ {
 				for x := 0; x < p.imageWidth; x++ {
@@ -226,6 +469,9 @@ Turns:
 					alive := 0
 					for y1 := y - 1; y1 <= y+1; y1++ {
 						for x1 := x - 1; x1 <= x+1; x1++ {
+							if p.bounded && (x1 < 0 || x1 >= p.imageWidth) {
+								continue
+							}
 							if x != x1 || y != y1 {
 								if world[y1][(x1+p.imageWidth)%p.imageWidth] == 0xFF {
 									alive++
@@ -245,38 +491,78 @@ Turns:
 					} else if world[y][x] == 0 && alive == 3 {
 						tempWorld[y][x] = 0xFF
 						numAlive++
+					} else {
+						tempWorld[y][x] = 0
 					}
 
 				}
 			}
 
+			var flippedCells []cell
 			for y := 1; y <= height; y++ {
 				for x := 0; x < p.imageWidth; x++ {
-					world[y][x] = tempWorld[y][x]
+					if world[y][x] != tempWorld[y][x] {
+						flippedCells = append(flippedCells, cell{x: x, y: startY + y - 1})
+						world[y][x] = tempWorld[y][x]
+						if flips != nil {
+							flips[y-1][x]++
+						}
+					}
+					if aliveTurns != nil && world[y][x] == 0xFF {
+						aliveTurns[y-1][x]++
+					}
 				}
 			}
 
+			haloStart := time.Now()
+
 			//Send/receive halos between neighbouring workers
 			for x := 0; x < p.imageWidth; x++ {
-				if num%2 == 0 {
-					topHalo <- world[1][x]
-					bottomHalo <- world[height][x]
+				halos.sendTop <- world[1][x]
+				halos.sendBottom <- world[height][x]
+			}
+			for x := 0; x < p.imageWidth; x++ {
+				world[0][x] = <-halos.top
+				world[height+1][x] = <-halos.bottom
+			}
+
+			// The halos beyond the edges of a bounded universe stay dead.
+			if p.bounded && startY == 0 {
+				for x := range world[0] {
+					world[0][x] = 0
 				}
-				world[height+1][x] = <-bottomHalo
-				world[0][x] = <-topHalo
-				if num%2 != 0 {
-					topHalo <- world[1][x]
-					bottomHalo <- world[height][x]
+			}
+			if p.bounded && startY+height == p.imageHeight {
+				for x := range world[height+1] {
+					world[height+1][x] = 0
 				}
 			}
+
+			timing := workerTiming{
+				start:   computeStart,
+				wait:    computeStart.Sub(idleSince),
+				compute: haloStart.Sub(computeStart),
+				halo:    time.Since(haloStart),
+				rows:    height,
+			}
+			golMetrics.workerTurn(num, timing)
+			idleSince = time.Now()
+			reports <- workerReport{worker: num, flipped: flippedCells, alive: numAlive, timing: timing}
 		}
 	}
+}
 
-	for y := 1; y <= height; y++ {
-		for x := 0; x < p.imageWidth; x++ {
-			val <- world[y][x]
+// aliveCells lists the coordinates of the alive cells in the world.
+func aliveCells(world [][]byte) []cell {
+	var alive []cell
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] != 0 {
+				alive = append(alive, cell{x: x, y: y})
+			}
 		}
 	}
+	return alive
 }
 
 func printGrid(world [][]byte) {
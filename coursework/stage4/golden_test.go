@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"uk.ac.bris.cs/gameoflife/reference"
+)
+
+// update writes any golden files that are missing, with 'go test -run TestGolden -update'.
+var update = flag.Bool("update", false, "Write the missing golden files in testdata/golden, checking them against the verified worlds.")
+
+// goldenSizes and goldenTurns are the images and turns there are golden files for.
+var (
+	goldenSizes = []int{16, 64, 128, 256, 512}
+	goldenTurns = []int{0, 1, 100, 1000}
+)
+
+// goldenThreads returns the thread counts in Test's table, which are all checked against each golden file.
+func goldenThreads() []int {
+	var threads []int
+	seen := make(map[int]bool)
+	for _, test := range tests {
+		if !seen[test.args.p.threads] {
+			seen[test.args.p.threads] = true
+			threads = append(threads, test.args.p.threads)
+		}
+	}
+	sort.Ints(threads)
+	return threads
+}
+
+// goldenPath is where the golden file for an image after some turns is kept.
+// They are pbm files, so any image viewer can show them.
+func goldenPath(size, turns int) string {
+	return filepath.Join("testdata", "golden", fmt.Sprintf("%dx%d_%d.pbm", size, size, turns))
+}
+
+// verifiedWorld returns the world after the given turns from a source that does not depend on any engine:
+// the bundled image itself for turn 0, or the alive cells the coursework's own tests expect.
+// It returns false if there is none.
+func verifiedWorld(size, turns int) ([][]byte, bool) {
+	if turns == 0 {
+		return readPgmWorld(fmt.Sprintf("images/%dx%d.pgm", size, size), size, size), true
+	}
+	for _, test := range tests {
+		p := test.args.p
+		if p.imageWidth == size && p.imageHeight == size && p.turns == turns {
+			return worldOf(p, test.args.expectedAlive), true
+		}
+	}
+	return nil, false
+}
+
+// updateGolden writes the golden files that are missing.
+// Each is the verified world if there is one, or else the reference engine's, which must agree with the verified worlds
+// and with any golden file already there. A golden file is never overwritten.
+func updateGolden(t *testing.T) {
+	check(os.MkdirAll(filepath.Join("testdata", "golden"), os.ModePerm))
+	for _, size := range goldenSizes {
+		world := readPgmWorld(fmt.Sprintf("images/%dx%d.pgm", size, size), size, size)
+		turn := 0
+		for _, turns := range goldenTurns {
+			world = reference.Run(world, turns-turn)
+			turn = turns
+			path := goldenPath(size, turns)
+
+			if verified, ok := verifiedWorld(size, turns); ok {
+				if d := golDiff(verified, world); !d.same() {
+					t.Errorf("The reference engine disagrees with the verified world for %s:\n%s", path, d.report("verified", "reference"))
+					continue
+				}
+			}
+			if data, err := ioutil.ReadFile(path); err == nil {
+				golden, err := decodeImage(data)
+				check(err)
+				if d := golDiff(golden, world); !d.same() {
+					t.Errorf("The reference engine disagrees with %s, which has been left as it is:\n%s", path, d.report("golden", "reference"))
+				}
+				continue
+			}
+			check(ioutil.WriteFile(path, encodeImage(formatPbm, world), 0644))
+			t.Log("Wrote", path)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	if *update {
+		updateGolden(t)
+	}
+
+	t.Run("verified", func(t *testing.T) {
+		for _, size := range goldenSizes {
+			for _, turns := range goldenTurns {
+				verified, ok := verifiedWorld(size, turns)
+				if !ok {
+					continue
+				}
+				data, err := ioutil.ReadFile(goldenPath(size, turns))
+				if err != nil {
+					continue
+				}
+				golden, err := decodeImage(data)
+				check(err)
+				if d := golDiff(verified, golden); !d.same() {
+					t.Errorf("%s is not the verified world:\n%s", goldenPath(size, turns), d.report("verified", "golden"))
+				}
+			}
+		}
+	})
+
+	// The runs load the bundled images and save their results in a directory of their own,
+	// so the golden files and images are read from here.
+	wd, err := os.Getwd()
+	check(err)
+	defer reference.InTempDir(t)()
+
+	for _, size := range goldenSizes {
+		image := fmt.Sprintf("%dx%d.pgm", size, size)
+		data, err := ioutil.ReadFile(filepath.Join(wd, "images", image))
+		check(err)
+		check(ioutil.WriteFile(filepath.Join("images", image), data, 0644))
+
+		for _, turns := range goldenTurns {
+			if testing.Short() && size*size*turns > 64*64*1000 {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(wd, goldenPath(size, turns)))
+			if !assert.NoError(t, err, "run 'go test -run TestGolden -update' to make the golden files") {
+				return
+			}
+			golden, err := decodeImage(data)
+			check(err)
+
+			for _, threads := range goldenThreads() {
+				p := golParams{turns: turns, threads: threads, imageWidth: size, imageHeight: size}
+				t.Run(fmt.Sprintf("%dx%dx%d-%d", size, size, threads, turns), func(t *testing.T) {
+					alive := gameOfLife(p, nil, nil)
+					if !assertAlive(t, p, aliveCells(golden), alive) {
+						return
+					}
+					saved := readPgmWorld(fmt.Sprintf("out/%dx%d_%d.pgm", size, size, turns), size, size)
+					if d := golDiff(golden, saved); !d.same() {
+						t.Error("The saved image is not as expected:\n" + d.report("golden", "saved"))
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// heatColours are the colours counts are mapped between, from no activity to the most on the board.
+var heatColours = []color.RGBA{
+	{0x00, 0x00, 0x00, 0xFF},
+	{0x50, 0x12, 0x7B, 0xFF},
+	{0xB6, 0x36, 0x79, 0xFF},
+	{0xFB, 0x87, 0x61, 0xFF},
+	{0xFC, 0xFD, 0xBF, 0xFF},
+}
+
+// generateHeatMap asks every worker for the counts in its section, merges them and saves them to out/.
+// Both how many turns each cell was alive for and how many times it flipped are saved,
+// as a colour mapped png to look at and a 16 bit pgm with the counts themselves.
+func generateHeatMap(p golParams, d distributorChans, turns int) {
+	if !p.heatMap {
+		return
+	}
+
+	aliveTurns := make([][]uint32, p.imageHeight)
+	flips := make([][]uint32, p.imageHeight)
+	for i := 0; i < p.threads; i++ {
+		d.workerCommands[i] <- workerHeat
+	}
+	for i := 0; i < p.threads; i++ {
+		heatMap := <-d.heatMaps
+		copy(aliveTurns[heatMap.startY:], heatMap.aliveTurns)
+		copy(flips[heatMap.startY:], heatMap.flips)
+	}
+
+	_ = os.Mkdir("out", os.ModePerm)
+	filename := "out/" + strconv.Itoa(p.imageWidth) + "x" + strconv.Itoa(p.imageHeight) + "_" + strconv.Itoa(turns)
+	writeHeatMap(filename+"_alive", aliveTurns)
+	writeHeatMap(filename+"_flips", flips)
+}
+
+// writeHeatMap saves the counts as filename.png and filename.pgm.
+func writeHeatMap(filename string, counts [][]uint32) {
+	check(ioutil.WriteFile(filename+".png", encodeHeatPng(counts), 0644))
+	check(ioutil.WriteFile(filename+".pgm", encodeHeatPgm(counts), 0644))
+	fmt.Println("File", filename, "output done!")
+}
+
+// encodeHeatPgm writes the counts as a P5 pgm with a maxval of 65535.
+// Each count takes two bytes, most significant first, and counts too big to fit are saved as 65535.
+func encodeHeatPgm(counts [][]uint32) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("P5\n" + strconv.Itoa(len(counts[0])) + " " + strconv.Itoa(len(counts)) + "\n65535\n")
+	value := make([]byte, 2)
+	for y := range counts {
+		for _, count := range counts[y] {
+			if count > 0xFFFF {
+				count = 0xFFFF
+			}
+			binary.BigEndian.PutUint16(value, uint16(count))
+			buffer.Write(value)
+		}
+	}
+	return buffer.Bytes()
+}
+
+// encodeHeatPng colours each cell by its count relative to the largest count on the board.
+func encodeHeatPng(counts [][]uint32) []byte {
+	var max uint32
+	for y := range counts {
+		for _, count := range counts[y] {
+			if count > max {
+				max = count
+			}
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, len(counts[0]), len(counts)))
+	for y := range counts {
+		for x, count := range counts[y] {
+			level := 0.0
+			if max > 0 {
+				level = float64(count) / float64(max)
+			}
+			img.SetRGBA(x, y, heatColour(level))
+		}
+	}
+
+	var buffer bytes.Buffer
+	check(png.Encode(&buffer, img))
+	return buffer.Bytes()
+}
+
+// heatColour picks a colour for a level between 0 and 1 by blending the two nearest heatColours.
+func heatColour(level float64) color.RGBA {
+	position := level * float64(len(heatColours)-1)
+	i := int(position)
+	if i >= len(heatColours)-1 {
+		return heatColours[len(heatColours)-1]
+	}
+	t := position - float64(i)
+	from, to := heatColours[i], heatColours[i+1]
+	blend := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+	return color.RGBA{blend(from.R, to.R), blend(from.G, to.G), blend(from.B, to.B), 0xFF}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readHeatPgm reads the counts back from a 16 bit pgm written by encodeHeatPgm.
+func readHeatPgm(t *testing.T, path string) [][]uint32 {
+	data, err := ioutil.ReadFile(path)
+	check(err)
+	magic, fields, pixels, err := readPnmHeader(data)
+	check(err)
+	assert.Equal(t, "P5", magic)
+	assert.Equal(t, 65535, fields[2])
+
+	width, height := fields[0], fields[1]
+	assert.Len(t, pixels, 2*width*height)
+	counts := make([][]uint32, height)
+	for y := range counts {
+		counts[y] = make([]uint32, width)
+		for x := range counts[y] {
+			counts[y][x] = uint32(binary.BigEndian.Uint16(pixels[2*(y*width+x):]))
+		}
+	}
+	return counts
+}
+
+func TestHeatMap(t *testing.T) {
+	// 6 threads do not divide 64 evenly, so the sections have different heights.
+	for _, threads := range []int{2, 6} {
+		p := golParams{
+			turns:       30,
+			threads:     threads,
+			imageWidth:  64,
+			imageHeight: 64,
+			heatMap:     true,
+		}
+		worlds := referenceWorlds(p, p.turns)
+		cleanOutput(t)
+
+		gameOfLife(p, nil, nil)
+
+		aliveTurns := make([][]uint32, p.imageHeight)
+		flips := make([][]uint32, p.imageHeight)
+		for y := range aliveTurns {
+			aliveTurns[y] = make([]uint32, p.imageWidth)
+			flips[y] = make([]uint32, p.imageWidth)
+			for x := range aliveTurns[y] {
+				for turn := 1; turn <= p.turns; turn++ {
+					if worlds[turn][y][x] != 0 {
+						aliveTurns[y][x]++
+					}
+					if worlds[turn][y][x] != worlds[turn-1][y][x] {
+						flips[y][x]++
+					}
+				}
+			}
+		}
+
+		assert.Equal(t, aliveTurns, readHeatPgm(t, "out/64x64_30_alive.pgm"), "%d threads", threads)
+		assert.Equal(t, flips, readHeatPgm(t, "out/64x64_30_flips.pgm"), "%d threads", threads)
+
+		data, err := ioutil.ReadFile("out/64x64_30_flips.png")
+		check(err)
+		img, err := png.Decode(bytes.NewReader(data))
+		check(err)
+		assert.Equal(t, p.imageWidth, img.Bounds().Dx())
+		assert.Equal(t, p.imageHeight, img.Bounds().Dy())
+		for y := range flips {
+			for x := range flips[y] {
+				r, g, b, _ := img.At(x, y).RGBA()
+				if flips[y][x] == 0 {
+					assert.Equal(t, [3]uint32{0, 0, 0}, [3]uint32{r, g, b}, "cell %d,%d never flipped", x, y)
+				} else {
+					assert.NotEqual(t, [3]uint32{0, 0, 0}, [3]uint32{r, g, b}, "cell %d,%d flipped", x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestHeatColour(t *testing.T) {
+	assert.Equal(t, heatColours[0], heatColour(0))
+	assert.Equal(t, heatColours[len(heatColours)-1], heatColour(1))
+	assert.Equal(t, heatColours[2], heatColour(0.5))
+}
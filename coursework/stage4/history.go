@@ -0,0 +1,96 @@
+package main
+
+import "unsafe"
+
+// cellSize is how much memory a flipped cell takes up in the history.
+const cellSize = int(unsafe.Sizeof(cell{}))
+
+// history remembers the cells flipped in each of the most recent turns, so the distributor can step back through them.
+// It is a ring buffer: once it holds p.historyTurns turns, or p.historyBytes bytes of flipped cells, the oldest turn is forgotten.
+type history struct {
+	maxTurns int
+	maxBytes int
+
+	// deltas[(start+i)%len(deltas)] holds the flips that made turn last-count+1+i, for i from 0 to count-1.
+	deltas [][][]cell
+	start  int
+	count  int
+	last   int
+	bytes  int
+}
+
+// newHistory returns an empty history, or nil if no history was asked for.
+func newHistory(p golParams) *history {
+	if p.historyTurns <= 0 {
+		return nil
+	}
+	return &history{maxTurns: p.historyTurns, maxBytes: p.historyBytes, deltas: make([][][]cell, p.historyTurns)}
+}
+
+// add records the flips that each worker made in the given turn.
+func (h *history) add(turns int, flipped [][]cell) {
+	if h == nil {
+		return
+	}
+	if h.count == h.maxTurns {
+		h.dropOldest()
+	}
+	h.last = turns
+	h.count++
+	h.deltas[(h.start+h.count-1)%len(h.deltas)] = flipped
+	for _, cells := range flipped {
+		h.bytes += len(cells) * cellSize
+	}
+
+	// The turn being added is always kept, even if it is bigger than the limit on its own.
+	for h.maxBytes > 0 && h.bytes > h.maxBytes && h.count > 1 {
+		h.dropOldest()
+	}
+}
+
+func (h *history) dropOldest() {
+	for _, flipped := range h.deltas[h.start] {
+		h.bytes -= len(flipped) * cellSize
+	}
+	h.deltas[h.start] = nil
+	h.start = (h.start + 1) % len(h.deltas)
+	h.count--
+}
+
+// oldest returns the earliest turn the world can be taken back to.
+func (h *history) oldest() int {
+	if h == nil || h.count == 0 {
+		return -1
+	}
+	return h.last - h.count
+}
+
+// flips returns the cells flipped to make the given turn from the one before.
+// Flipping them again goes back a turn, and flipping them once more goes forward again.
+func (h *history) flips(turns int) [][]cell {
+	return h.deltas[(h.start+h.count-1-(h.last-turns))%len(h.deltas)]
+}
+
+// truncate forgets the turns after the given one, so that a new run can branch from it.
+func (h *history) truncate(turns int) {
+	if h == nil {
+		return
+	}
+	for h.count > 0 && h.last > turns {
+		i := (h.start + h.count - 1) % len(h.deltas)
+		for _, flipped := range h.deltas[i] {
+			h.bytes -= len(flipped) * cellSize
+		}
+		h.deltas[i] = nil
+		h.count--
+		h.last--
+	}
+}
+
+// clear forgets every turn.
+func (h *history) clear() {
+	h.truncate(-1)
+	if h != nil {
+		h.start = 0
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	// flips makes the flips for a turn, with the given number of cells flipped by each worker.
+	flips := func(n ...int) [][]cell {
+		var flipped [][]cell
+		for _, cells := range n {
+			flipped = append(flipped, make([]cell, cells))
+		}
+		return flipped
+	}
+
+	t.Run("turn limit", func(t *testing.T) {
+		h := newHistory(golParams{historyTurns: 3})
+		for turn := 1; turn <= 5; turn++ {
+			h.add(turn, flips(turn, 1))
+		}
+		assert.Equal(t, 2, h.oldest())
+		assert.Equal(t, flips(5, 1), h.flips(5))
+		assert.Equal(t, flips(3, 1), h.flips(3))
+		assert.Equal(t, (3+4+5+3)*cellSize, h.bytes)
+	})
+
+	t.Run("memory limit", func(t *testing.T) {
+		h := newHistory(golParams{historyTurns: 100, historyBytes: 10 * cellSize})
+		for turn := 1; turn <= 5; turn++ {
+			h.add(turn, flips(4))
+		}
+		// Only two turns of 4 cells fit in 10 cells.
+		assert.Equal(t, 3, h.oldest())
+		assert.Equal(t, 8*cellSize, h.bytes)
+
+		// A turn bigger than the limit is still kept on its own.
+		h.add(6, flips(20))
+		assert.Equal(t, 5, h.oldest())
+		assert.Equal(t, 20*cellSize, h.bytes)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		h := newHistory(golParams{historyTurns: 4})
+		for turn := 1; turn <= 6; turn++ {
+			h.add(turn, flips(turn))
+		}
+		h.truncate(4)
+		assert.Equal(t, 2, h.oldest())
+		assert.Equal(t, (3+4)*cellSize, h.bytes)
+		h.add(5, flips(1))
+		assert.Equal(t, flips(1), h.flips(5))
+		assert.Equal(t, 2, h.oldest())
+
+		h.clear()
+		assert.Equal(t, -1, h.oldest())
+		assert.Equal(t, 0, h.bytes)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		h := newHistory(golParams{})
+		h.add(1, flips(1))
+		assert.Equal(t, -1, h.oldest())
+	})
+}
+
+func TestTimeTravel(t *testing.T) {
+	p := golParams{
+		turns:        1000000000,
+		threads:      4,
+		imageWidth:   16,
+		imageHeight:  16,
+		historyTurns: 5,
+	}
+	worlds := referenceWorlds(p, 16)
+	cleanOutput(t)
+
+	snapshot := func(t *testing.T, turn int) {
+		path := "out/16x16_" + strconv.Itoa(turn) + ".pgm"
+		assertAlive(t, p, aliveCells(worlds[turn]), aliveCells(readPgmWorld(path, 16, 16)), path)
+	}
+
+	t.Run("back and forward", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{10, "pbbbsfsq"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[8]), alive)
+		snapshot(t, 7)
+		snapshot(t, 8)
+	})
+
+	t.Run("history limit", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{10, "pbbbbbbbbq"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[5]), alive)
+	})
+
+	t.Run("ignored while executing", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{10, "bbb"}, {12, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[12]), alive)
+	})
+
+	t.Run("branch by resuming", func(t *testing.T) {
+		// After going back to turn 7 the workers start again from there, so turn 9 comes round a second time.
+		alive := runScript(p, []scriptStep{{10, "pbbbp"}, {9, "s"}, {13, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[13]), alive)
+		snapshot(t, 9)
+	})
+
+	t.Run("branch by stepping", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{10, "pbbn"}, {9, "sq"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[9]), alive)
+		snapshot(t, 9)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		// Going back to turn 6 and resetting forgets the history, so 'b' has nothing to go back to.
+		alive := runScript(p, []scriptStep{{8, "pbbrbbbn"}, {1, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[1]), alive)
+	})
+}
+
+// TestBranchHeatMap steps back and resumes with the heat maps on, which carries on from the turn the workers
+// were on instead of branching, so the heat maps count every turn once.
+func TestBranchHeatMap(t *testing.T) {
+	p := golParams{
+		turns:        20,
+		threads:      4,
+		imageWidth:   16,
+		imageHeight:  16,
+		historyTurns: 5,
+		heatMap:      true,
+	}
+	cleanOutput(t)
+	heatPath := "out/16x16_20_"
+
+	gameOfLife(p, nil, nil)
+	alive, flips := readHeatPgm(t, heatPath+"alive.pgm"), readHeatPgm(t, heatPath+"flips.pgm")
+
+	var result []cell
+	out := captureStdout(func() {
+		result = runScript(p, []scriptStep{{10, "pbbbp"}}, nil)
+	})
+	assert.Contains(t, out, "Cannot branch")
+	assertAlive(t, p, aliveCells(referenceWorlds(p, p.turns)[p.turns]), result)
+	assert.Equal(t, alive, readHeatPgm(t, heatPath+"alive.pgm"))
+	assert.Equal(t, flips, readHeatPgm(t, heatPath+"flips.pgm"))
+}
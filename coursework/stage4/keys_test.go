@@ -0,0 +1,291 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"uk.ac.bris.cs/gameoflife/reference"
+)
+
+// keyPress is one step of a scripted key sequence: a key, or a pause before the next key.
+type keyPress struct {
+	key  rune
+	wait time.Duration
+}
+
+// press builds a script that presses each key in turn.
+func press(keys string) []keyPress {
+	var script []keyPress
+	for _, key := range keys {
+		script = append(script, keyPress{key: key})
+	}
+	return script
+}
+
+// wait builds a script step that does nothing for the given time.
+func wait(d time.Duration) []keyPress {
+	return []keyPress{{wait: d}}
+}
+
+// script joins script steps together.
+func script(steps ...[]keyPress) []keyPress {
+	var joined []keyPress
+	for _, step := range steps {
+		joined = append(joined, step...)
+	}
+	return joined
+}
+
+// runKeys runs the Game of Life while the keys are pressed one after another.
+func runKeys(p golParams, keys []keyPress) []cell {
+	keyChan := make(chan rune)
+	go func() {
+		for _, k := range keys {
+			time.Sleep(k.wait)
+			if k.key != 0 {
+				keyChan <- k.key
+			}
+		}
+	}()
+	return gameOfLife(p, keyChan, nil)
+}
+
+// captureStdout returns everything printed to stdout while f runs.
+func captureStdout(f func()) string {
+	r, w, err := os.Pipe()
+	check(err)
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		out <- string(data)
+	}()
+	f()
+	os.Stdout = stdout
+	_ = w.Close()
+	return <-out
+}
+
+// scriptStep presses keys as soon as the given turn has been sent to the workers.
+// A step for turn 0 that comes first in a script presses its keys before the first turn.
+type scriptStep struct {
+	turn int
+	keys string
+}
+
+// runScript runs the Game of Life and works through the script one step after another.
+// The events chan, which may be nil, is passed on to gameOfLife.
+func runScript(p golParams, steps []scriptStep, events chan<- Event) []cell {
+	total := 0
+	for _, step := range steps {
+		total += len(step.keys)
+	}
+	keyChan := make(chan rune, total)
+
+	pressAt := func(turns int) {
+		for len(steps) > 0 && steps[0].turn == turns {
+			for _, key := range steps[0].keys {
+				keyChan <- key
+			}
+			steps = steps[1:]
+		}
+	}
+
+	pressAt(0)
+	p.test.afterTurn = pressAt
+	return gameOfLife(p, keyChan, events)
+}
+
+// readPgmWorld reads an image written by the io goroutine into a 2D slice.
+func readPgmWorld(path string, width, height int) [][]byte {
+	data, err := ioutil.ReadFile(path)
+	check(err)
+	world, err := decodeImage(data)
+	check(err)
+	if len(world) != height || len(world[0]) != width {
+		panic("Incorrect size")
+	}
+	return world
+}
+
+// cleanOutput removes the files that the test adds to out/ once it has finished.
+func cleanOutput(t *testing.T) {
+	existing := make(map[string]bool)
+	before, _ := filepath.Glob("out/*")
+	for _, path := range before {
+		existing[path] = true
+	}
+	t.Cleanup(func() {
+		after, _ := filepath.Glob("out/*")
+		for _, path := range after {
+			if !existing[path] {
+				_ = os.Remove(path)
+			}
+		}
+	})
+}
+
+// referenceWorlds loads the image for p and calculates the first n turns sequentially.
+// It returns the world after each turn, starting with the loaded image.
+func referenceWorlds(p golParams, n int) [][][]byte {
+	world := readPgmWorld("images/"+strconv.Itoa(p.imageWidth)+"x"+strconv.Itoa(p.imageHeight)+".pgm", p.imageWidth, p.imageHeight)
+
+	worlds := [][][]byte{world}
+	for turn := 0; turn < n; turn++ {
+		world = referenceStep(p, world)
+		worlds = append(worlds, world)
+	}
+	return worlds
+}
+
+// referenceStep calculates one turn with the shared reference engine.
+// Cells beyond the edges of a bounded universe are dead, otherwise the world wraps around.
+func referenceStep(p golParams, world [][]byte) [][]byte {
+	if p.bounded {
+		return reference.StepBounded(world)
+	}
+	return reference.Step(world)
+}
+
+// turnOf returns the first of the reference turns whose alive cells match, or -1 if none do.
+func turnOf(alive []cell, worlds [][][]byte) int {
+	set := make(map[cell]bool)
+	for _, c := range alive {
+		set[c] = true
+	}
+	for turn, world := range worlds {
+		expected := aliveCells(world)
+		if len(expected) != len(set) {
+			continue
+		}
+		match := true
+		for _, c := range expected {
+			if !set[c] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return turn
+		}
+	}
+	return -1
+}
+
+func TestKeys(t *testing.T) {
+	p := golParams{
+		turns:       1000000000,
+		threads:     4,
+		imageWidth:  16,
+		imageHeight: 16,
+	}
+	worlds := referenceWorlds(p, 16)
+	cleanOutput(t)
+
+	t.Run("reset", func(t *testing.T) {
+		alive := runKeys(p, press("prq"))
+		assertAlive(t, p, aliveCells(worlds[0]), alive)
+	})
+
+	t.Run("single step", func(t *testing.T) {
+		alive := runKeys(p, press("prnnnq"))
+		assertAlive(t, p, aliveCells(worlds[3]), alive)
+	})
+
+	t.Run("slow down", func(t *testing.T) {
+		// 8 presses go from unlimited to 5 turns/s.
+		p := p
+		clock := &fakeClock{}
+		p.test.clock = clock
+		alive := runScript(p, []scriptStep{{0, "--------"}, {5, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[5]), alive, "turn 5")
+		waits := clock.lastWaits(5)
+		if assert.Len(t, waits, 5) {
+			for _, w := range waits {
+				assert.Equal(t, 200*time.Millisecond, w)
+			}
+		}
+	})
+
+	t.Run("speed up", func(t *testing.T) {
+		// 10 presses go down to 1 turn/s, 3 more go back up to 10 turns/s.
+		p := p
+		clock := &fakeClock{}
+		p.test.clock = clock
+		alive := runScript(p, []scriptStep{{0, "----------+++"}, {5, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[5]), alive, "turn 5")
+		waits := clock.lastWaits(5)
+		if assert.Len(t, waits, 5) {
+			for _, w := range waits {
+				assert.Equal(t, 100*time.Millisecond, w)
+			}
+		}
+	})
+
+	t.Run("toggle alive reports", func(t *testing.T) {
+		// The fake ticker is always ready, so a report is due between any two turns.
+		p := p
+		p.test.clock = &fakeClock{}
+
+		out := captureStdout(func() {
+			runScript(p, []scriptStep{{0, "k"}, {10, "k"}, {20, "q"}}, nil)
+		})
+		off := strings.Index(out, "Alive cell reports off")
+		on := strings.Index(out, "Alive cell reports on")
+		if assert.True(t, off >= 0 && on > off, out) {
+			assert.NotContains(t, out[off:on], "alive:")
+			assert.Contains(t, out[on:], "alive:")
+		}
+	})
+}
+
+func TestScriptedKeys(t *testing.T) {
+	p := golParams{
+		turns:       1000000000,
+		threads:     4,
+		imageWidth:  16,
+		imageHeight: 16,
+	}
+	worlds := referenceWorlds(p, 16)
+	cleanOutput(t)
+
+	// snapshot checks the pgm file saved at the given turn.
+	snapshot := func(t *testing.T, turn int) {
+		path := "out/16x16_" + strconv.Itoa(turn) + ".pgm"
+		assertAlive(t, p, aliveCells(worlds[turn]), aliveCells(readPgmWorld(path, 16, 16)), path)
+	}
+
+	t.Run("quit", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{7, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[7]), alive)
+		snapshot(t, 7)
+	})
+
+	t.Run("snapshots", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{3, "s"}, {9, "s"}, {12, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[12]), alive)
+		snapshot(t, 3)
+		snapshot(t, 9)
+		snapshot(t, 12)
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{4, "psn"}, {5, "nq"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[6]), alive)
+		snapshot(t, 4)
+		snapshot(t, 6)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		alive := runScript(p, []scriptStep{{5, "r"}, {3, "q"}}, nil)
+		assertAlive(t, p, aliveCells(worlds[3]), alive)
+		snapshot(t, 3)
+	})
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clientBuffer is how many frames may wait for a slow client before frames are dropped.
+const clientBuffer = 64
+
+// fullFrameInterval is how often every client is sent the whole board, so a missed diff is never shown for long.
+var fullFrameInterval = time.Second
+
+// liveMessage is sent to browsers as JSON.
+// Full frames list the alive cells and diffs list the flipped cells.
+type liveMessage struct {
+	Type   string   `json:"type"`
+	Turn   int      `json:"turn"`
+	Width  int      `json:"width,omitempty"`
+	Height int      `json:"height,omitempty"`
+	Cells  [][2]int `json:"cells,omitempty"`
+	Alive  int      `json:"alive,omitempty"`
+	State  string   `json:"state,omitempty"`
+	Path   string   `json:"path,omitempty"`
+}
+
+// liveClient is a browser watching the run.
+type liveClient struct {
+	conn      *wsConn
+	frames    chan []byte
+	needsFull bool
+	lastFull  time.Time
+}
+
+// liveView streams the board to browsers over WebSockets and passes their key presses on to the distributor.
+type liveView struct {
+	p    golParams
+	keys chan<- rune
+	done chan struct{}
+
+	mutex   sync.Mutex
+	clients map[*liveClient]bool
+	world   [][]byte
+	turn    int
+	pending [][2]int
+}
+
+func newLiveView(p golParams, keys chan<- rune) *liveView {
+	world := make([][]byte, p.imageHeight)
+	for i := range world {
+		world[i] = make([]byte, p.imageWidth)
+	}
+	return &liveView{
+		p:       p,
+		keys:    keys,
+		done:    make(chan struct{}),
+		clients: make(map[*liveClient]bool),
+		world:   world,
+	}
+}
+
+// run follows the events from the distributor until the events chan is closed.
+// It never waits for a client, so a slow browser cannot hold up the workers.
+func (v *liveView) run(events <-chan Event) {
+	for e := range events {
+		v.mutex.Lock()
+		switch e := e.(type) {
+		case CellFlipped:
+			v.world[e.cell.y][e.cell.x] = ^v.world[e.cell.y][e.cell.x]
+			v.pending = append(v.pending, [2]int{e.cell.x, e.cell.y})
+		case TurnComplete:
+			if e.turn == 0 {
+				// The world has been reset, which is a bigger change than a diff should carry.
+				for client := range v.clients {
+					client.needsFull = true
+				}
+			}
+			v.turn = e.turn
+			v.sendDiff()
+		case AliveCount:
+			v.broadcast(liveMessage{Type: "alive", Turn: e.turn, Alive: e.n})
+		case StateChange:
+			v.broadcast(liveMessage{Type: "state", Turn: e.turn, State: e.state.String()})
+		case ImageSaved:
+			v.broadcast(liveMessage{Type: "saved", Turn: e.turn, Path: e.path})
+		}
+		v.mutex.Unlock()
+	}
+
+	// The run has finished, so bring every client up to date with the final board.
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for client := range v.clients {
+		client.needsFull = true
+	}
+	v.sendDiff()
+	close(v.done)
+	for client := range v.clients {
+		close(client.frames)
+	}
+	v.clients = nil
+}
+
+// fullFrame lists every alive cell. It must be called with the mutex held.
+func (v *liveView) fullFrame() []byte {
+	message := liveMessage{Type: "full", Turn: v.turn, Width: v.p.imageWidth, Height: v.p.imageHeight}
+	for y := range v.world {
+		for x := range v.world[y] {
+			if v.world[y][x] != 0 {
+				message.Cells = append(message.Cells, [2]int{x, y})
+			}
+		}
+	}
+	frame, err := json.Marshal(message)
+	check(err)
+	return frame
+}
+
+// sendDiff sends the cells flipped since the last diff to every client that is up to date,
+// and a full frame to the others. It must be called with the mutex held.
+func (v *liveView) sendDiff() {
+	var diff, full []byte
+	for client := range v.clients {
+		var frame []byte
+		sendingFull := client.needsFull || time.Since(client.lastFull) >= fullFrameInterval
+		if sendingFull {
+			if full == nil {
+				full = v.fullFrame()
+			}
+			frame = full
+		} else {
+			if diff == nil {
+				var err error
+				diff, err = json.Marshal(liveMessage{Type: "diff", Turn: v.turn, Cells: v.pending})
+				check(err)
+			}
+			frame = diff
+		}
+
+		select {
+		case client.frames <- frame:
+			client.needsFull = false
+			if sendingFull {
+				client.lastFull = time.Now()
+			}
+		default:
+			// The client has fallen behind. Its board is now out of date, so it needs a full frame next.
+			client.needsFull = true
+		}
+	}
+	v.pending = v.pending[:0]
+}
+
+// broadcast sends a message to every client that has room for it. It must be called with the mutex held.
+func (v *liveView) broadcast(message liveMessage) {
+	frame, err := json.Marshal(message)
+	check(err)
+	for client := range v.clients {
+		select {
+		case client.frames <- frame:
+		default:
+		}
+	}
+}
+
+// serveWebSocket sends frames to a browser and passes the keys it sends on to the distributor.
+func (v *liveView) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	client := &liveClient{conn: conn, frames: make(chan []byte, clientBuffer)}
+
+	v.mutex.Lock()
+	if v.clients == nil {
+		v.mutex.Unlock()
+		conn.close()
+		return
+	}
+	client.frames <- v.fullFrame()
+	client.lastFull = time.Now()
+	v.clients[client] = true
+	v.mutex.Unlock()
+
+	go func() {
+		for {
+			message, err := conn.readMessage()
+			if err != nil {
+				v.removeClient(client)
+				return
+			}
+			for _, key := range string(message) {
+				select {
+				case v.keys <- key:
+				case <-v.done:
+				}
+			}
+		}
+	}()
+
+	for frame := range client.frames {
+		if err := conn.writeFrame(wsText, frame); err != nil {
+			v.removeClient(client)
+			break
+		}
+	}
+	conn.close()
+}
+
+// removeClient stops sending frames to a client.
+func (v *liveView) removeClient(client *liveClient) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.clients[client] {
+		delete(v.clients, client)
+		close(client.frames)
+	}
+}
+
+// handler serves the page on / and the WebSocket on /ws.
+func (v *liveView) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(livePage))
+	})
+	mux.HandleFunc("/ws", v.serveWebSocket)
+	return mux
+}
+
+// startLiveServer serves the live view on the given address in the background.
+func startLiveServer(addr string, p golParams, keys chan<- rune, events <-chan Event) {
+	v := newLiveView(p, keys)
+	go v.run(events)
+	go func() {
+		check(http.ListenAndServe(addr, v.handler()))
+	}()
+	fmt.Println("Live view:", addr)
+}
+
+// livePage draws the board on a canvas and sends key presses and button clicks back over the WebSocket.
+const livePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Game of Life</title>
+<style>
+body { font-family: sans-serif; background: #222; color: #eee; }
+canvas { image-rendering: pixelated; border: 1px solid #555; display: block; margin-top: 8px; }
+</style>
+</head>
+<body>
+<div>
+<button data-key="p">Pause / resume</button>
+<button data-key="b">Back</button>
+<button data-key="f">Forward</button>
+<button data-key="s">Save PGM</button>
+<button data-key="q">Quit</button>
+<span id="status">Connecting...</span>
+</div>
+<canvas id="board" width="0" height="0"></canvas>
+<script>
+const canvas = document.getElementById("board");
+const context = canvas.getContext("2d");
+const status = document.getElementById("status");
+let width = 0, height = 0, cells = new Uint8Array(0), image = null;
+let turn = 0, alive = "", state = "Executing", drawing = false;
+
+function showStatus() {
+	status.textContent = "Turn " + turn + " - " + state + (alive === "" ? "" : " - " + alive + " alive");
+}
+
+function draw() {
+	drawing = false;
+	for (let i = 0; i < cells.length; i++) {
+		const v = cells[i] ? 255 : 0;
+		image.data[4 * i] = v;
+		image.data[4 * i + 1] = v;
+		image.data[4 * i + 2] = v;
+		image.data[4 * i + 3] = 255;
+	}
+	context.putImageData(image, 0, 0);
+	showStatus();
+}
+
+function scheduleDraw() {
+	if (!drawing && image !== null) {
+		drawing = true;
+		requestAnimationFrame(draw);
+	}
+}
+
+const socket = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws");
+socket.onopen = () => { status.textContent = "Connected"; };
+socket.onclose = () => { state = "Disconnected"; showStatus(); };
+socket.onmessage = (event) => {
+	const message = JSON.parse(event.data);
+	switch (message.type) {
+	case "full":
+		if (message.width !== width || message.height !== height) {
+			width = message.width;
+			height = message.height;
+			canvas.width = width;
+			canvas.height = height;
+			canvas.style.width = (width * Math.max(1, Math.floor(512 / width))) + "px";
+			image = context.createImageData(width, height);
+		}
+		cells = new Uint8Array(width * height);
+		(message.cells || []).forEach(([x, y]) => { cells[y * width + x] = 1; });
+		break;
+	case "diff":
+		(message.cells || []).forEach(([x, y]) => { cells[y * width + x] ^= 1; });
+		break;
+	case "alive":
+		alive = message.alive;
+		break;
+	case "state":
+		state = message.state;
+		break;
+	case "saved":
+		state = "Saved " + message.path;
+		break;
+	}
+	turn = message.turn;
+	scheduleDraw();
+};
+
+document.querySelectorAll("button").forEach((button) => {
+	button.onclick = () => socket.send(button.dataset.key);
+});
+document.addEventListener("keydown", (event) => {
+	if (event.key.length === 1 && !event.ctrlKey && !event.metaKey) {
+		socket.send(event.key);
+	}
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dialWebSocket opens a WebSocket to the test server's /ws as a browser would.
+func dialWebSocket(t *testing.T, server *httptest.Server) *wsConn {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	check(err)
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	_, err = conn.Write([]byte("GET /ws HTTP/1.1\r\n" +
+		"Host: " + conn.RemoteAddr().String() + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+	check(err)
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	response, err := http.ReadResponse(rw.Reader, nil)
+	check(err)
+	assert.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+	// The example key and accept value from RFC 6455.
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", response.Header.Get("Sec-WebSocket-Accept"))
+	return &wsConn{conn: conn, rw: rw, mask: true}
+}
+
+func TestLiveView(t *testing.T) {
+	p := golParams{
+		turns:       1000000000,
+		threads:     4,
+		imageWidth:  16,
+		imageHeight: 16,
+	}
+	// The glider in the 16x16 image gets back to where it started every 64 turns.
+	worlds := referenceWorlds(p, 63)
+	cleanOutput(t)
+
+	t.Run("page", func(t *testing.T) {
+		server := httptest.NewServer(newLiveView(p, nil).handler())
+		defer server.Close()
+		response, err := http.Get(server.URL)
+		check(err)
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(t, "text/html; charset=utf-8", response.Header.Get("Content-Type"))
+		assert.Contains(t, string(body), "new WebSocket(")
+	})
+
+	t.Run("board and keys", func(t *testing.T) {
+		keyChan := make(chan rune)
+		events := make(chan Event)
+		view := newLiveView(p, keyChan)
+		server := httptest.NewServer(view.handler())
+		defer server.Close()
+		client := dialWebSocket(t, server)
+		go view.run(events)
+
+		result := make(chan []cell)
+		go func() {
+			result <- gameOfLife(p, keyChan, events)
+		}()
+
+		world := make([][]byte, p.imageHeight)
+		for y := range world {
+			world[y] = make([]byte, p.imageWidth)
+		}
+		var states []string
+		quitSent := false
+		for {
+			data, err := client.readMessage()
+			if err != nil {
+				break
+			}
+			var message liveMessage
+			check(json.Unmarshal(data, &message))
+
+			switch message.Type {
+			case "full":
+				for y := range world {
+					world[y] = make([]byte, p.imageWidth)
+				}
+				for _, c := range message.Cells {
+					world[c[1]][c[0]] = 0xFF
+				}
+			case "diff":
+				for _, c := range message.Cells {
+					world[c[1]][c[0]] = ^world[c[1]][c[0]]
+				}
+			case "state":
+				states = append(states, message.State)
+				continue
+			default:
+				continue
+			}
+
+			if message.Turn > 0 {
+				expected := worlds[message.Turn%len(worlds)]
+				assertAlive(t, p, aliveCells(expected), aliveCells(world), "turn %d", message.Turn)
+			}
+			if message.Turn >= 20 && !quitSent {
+				check(client.writeFrame(wsText, []byte("q")))
+				quitSent = true
+			}
+		}
+
+		select {
+		case alive := <-result:
+			assertAlive(t, p, aliveCells(world), alive)
+			assert.Contains(t, states, "Quitting")
+		case <-time.After(10 * time.Second):
+			t.Fatal("q sent over the WebSocket did not stop the run")
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		events := make(chan Event)
+		view := newLiveView(p, nil)
+		server := httptest.NewServer(view.handler())
+		defer server.Close()
+		client := dialWebSocket(t, server)
+		defer client.conn.Close()
+		go view.run(events)
+
+		// next reads the next board message, after the client has been sent the first full frame.
+		next := func() liveMessage {
+			data, err := client.readMessage()
+			check(err)
+			var message liveMessage
+			check(json.Unmarshal(data, &message))
+			return message
+		}
+		assert.Equal(t, "full", next().Type)
+
+		events <- CellFlipped{1, cell{x: 3, y: 4}}
+		events <- TurnComplete{1}
+		assert.Equal(t, liveMessage{Type: "diff", Turn: 1, Cells: [][2]int{{3, 4}}}, next())
+
+		// A reset is the flips back to the loaded image, then turn 0 again.
+		events <- CellFlipped{0, cell{x: 3, y: 4}}
+		events <- CellFlipped{0, cell{x: 5, y: 6}}
+		events <- TurnComplete{0}
+		assert.Equal(t, liveMessage{Type: "full", Turn: 0, Width: 16, Height: 16, Cells: [][2]int{{5, 6}}}, next())
+		close(events)
+	})
+
+	t.Run("unmasked frame", func(t *testing.T) {
+		view := newLiveView(p, nil)
+		server := httptest.NewServer(view.handler())
+		defer server.Close()
+		client := dialWebSocket(t, server)
+		defer client.conn.Close()
+
+		// Clients must mask every frame, so the server closes the connection on one that is not.
+		client.mask = false
+		check(client.writeFrame(wsText, []byte("q")))
+		client.mask = true
+		var err error
+		for err == nil {
+			_, err = client.readMessage()
+		}
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("slow client", func(t *testing.T) {
+		p := golParams{
+			turns:       2000,
+			threads:     4,
+			imageWidth:  64,
+			imageHeight: 64,
+		}
+		events := make(chan Event)
+		view := newLiveView(p, nil)
+		server := httptest.NewServer(view.handler())
+		defer server.Close()
+
+		// This client never reads, so the server's writes back up and frames have to be dropped.
+		client := dialWebSocket(t, server)
+		defer client.conn.Close()
+		go view.run(events)
+
+		done := make(chan bool)
+		go func() {
+			gameOfLife(p, nil, events)
+			done <- true
+		}()
+		select {
+		case <-done:
+		case <-time.After(20 * time.Second):
+			t.Fatal("a client that does not read stalled the workers")
+		}
+	})
+}
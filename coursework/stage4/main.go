@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"time"
 )
 
 // golParams provides the details of how to run the Game of Life and which image to load.
@@ -11,6 +13,73 @@ type golParams struct {
 	threads     int
 	imageWidth  int
 	imageHeight int
+
+	// gifPath, when set, is where an animated GIF of the run is written.
+	// A frame is added every gifEvery turns, with each cell drawn gifScale pixels wide.
+	gifPath  string
+	gifEvery int
+	gifScale int
+
+	// outFormat is the format images are saved in: pgm, pbm or png. Empty means pgm.
+	outFormat string
+
+	// heatMap makes the workers count how many turns each cell was alive for and how many times it flipped.
+	heatMap bool
+
+	// statsEvery, when above 0, is how many turns apart rows of the population history are saved.
+	// statsFormat is csv or jsonl. Empty means csv.
+	statsEvery  int
+	statsFormat string
+
+	// bounded makes the edges of the board the edges of the universe, instead of wrapping around.
+	bounded bool
+
+	// trackShips makes the distributor follow the spaceships on the board and report how they move.
+	trackShips bool
+
+	// historyTurns is how many of the latest turns can be stepped back through with 'b' while paused. 0 turns the history off.
+	// historyBytes, when above 0, also limits the memory the history uses.
+	historyTurns int
+	historyBytes int
+
+	// recordPath, when set, is where the run is recorded, to be played back with 'gameoflife replay'.
+	recordPath string
+
+	// cpuProfile, memProfile and executionTrace, when set, are the names of files in out/ to write
+	// a CPU profile, a memory profile and an execution trace of the whole run to.
+	cpuProfile     string
+	memProfile     string
+	executionTrace string
+
+	// timing prints how long each worker spent calculating, waiting for halos and waiting on the distributor,
+	// and how unevenly the work was shared, at the end of the run.
+	// timeline, when set, is the name of a file in out/ to write those times to turn by turn, for chrome://tracing.
+	timing   bool
+	timeline string
+
+	// aliveInterval is how often the number of alive cells is reported. 0 means defaultAliveInterval.
+	aliveInterval time.Duration
+
+	// rebalanceEvery, when above 0, is how many turns apart the distributor moves rows between the workers,
+	// so that they take about as long as each other. 0 keeps the sections as they were first shared out.
+	rebalanceEvery int
+
+	// test holds what only the tests change.
+	test testOptions
+}
+
+// testOptions let the tests take the place of parts of the run that they cannot otherwise control.
+type testOptions struct {
+	// clock is where the distributor gets the time from. Nil means the time of the machine.
+	clock clock
+
+	// afterTurn, when set, is called by the distributor each time the workers have been sent a turn.
+	// The tests use it to press keys at known turns.
+	afterTurn func(turns int)
+
+	// chooseSections picks the rows each worker should have from the time they took since the last rebalance.
+	// Nil means balanceSections. The tests set it to move rows about as they like.
+	chooseSections func(s sections, compute []time.Duration) sections
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
@@ -30,11 +99,29 @@ const (
 type workerCommand uint8
 
 const (
-	workerCurentPGM workerCommand = iota
-	workerQuit
-	workerSendAlive
+	workerQuit workerCommand = iota
+	workerLoad
+	workerHeat
+	workerRebalance
 )
 
+// workerReport is sent by each worker once it has finished a turn.
+// It lists the cells the worker flipped and counts the cells alive in its section after the turn.
+type workerReport struct {
+	worker  int
+	flipped []cell
+	alive   int
+	timing  workerTiming
+}
+
+// workerHeatMap is sent by each worker when asked for its heat map.
+// For each cell in its section it counts the turns the cell was alive after and the times it flipped.
+type workerHeatMap struct {
+	startY     int
+	aliveTurns [][]uint32
+	flips      [][]uint32
+}
+
 // cell is used as the return type for the testing framework.
 type cell struct {
 	x, y int
@@ -67,9 +154,12 @@ type distributorChans struct {
 	io              distributorToIo
 	key             <-chan rune
 	workerVals      []chan uint8
-	aliveWorkers    chan int
 	workerCommands  []chan workerCommand
 	workerNextTurns []chan uint8
+	workerMoves     []chan rowMove
+	reports         chan workerReport
+	heatMaps        chan workerHeatMap
+	events          chan<- Event
 }
 
 // ioChans stores all the chans that the io goroutine will use.
@@ -77,13 +167,37 @@ type ioChans struct {
 	distributor ioToDistributor
 }
 
+// workerHalos are the chans a worker swaps the rows at the edges of its section on.
+// Each goes one way between a pair of neighbours and holds a whole row, so a worker can
+// send both of its edge rows before receiving any, whatever the number of workers.
+type workerHalos struct {
+	sendTop, sendBottom chan<- uint8
+	top, bottom         <-chan uint8
+}
+
 // gameOfLife is the function called by the testing framework.
 // It makes some channels and starts relevant goroutines.
 // It places the created channels in the relevant structs.
 // It returns an array of alive cells returned by the distributor.
-func gameOfLife(p golParams, keyChan <-chan rune) []cell {
+// If events is not nil the distributor reports what it is doing on it, and closes it when it has finished.
+// Every cell that flips is sent on it, so give it a buffer unless the receiver keeps up with the workers.
+func gameOfLife(p golParams, keyChan <-chan rune, events chan<- Event) []cell {
 	fmt.Println("----START", p.imageHeight, p.threads)
 
+	// Every worker needs at least one row.
+	if p.threads > p.imageHeight {
+		p.threads = p.imageHeight
+	}
+	if p.aliveInterval == 0 {
+		p.aliveInterval = defaultAliveInterval
+	}
+	if p.test.clock == nil {
+		p.test.clock = realClock{}
+	}
+	if p.test.chooseSections == nil {
+		p.test.chooseSections = balanceSections
+	}
+
 	var dChans distributorChans
 	var ioChans ioChans
 
@@ -109,30 +223,42 @@ func gameOfLife(p golParams, keyChan <-chan rune) []cell {
 
 	dChans.key = keyChan
 
-	aliveWorkers := make(chan int)
-	dChans.aliveWorkers = aliveWorkers
+	dChans.events = events
+
+	reports := make(chan workerReport)
+	dChans.reports = reports
+
+	heatMaps := make(chan workerHeatMap)
+	dChans.heatMaps = heatMaps
 
 	var workerCommands []chan workerCommand
 	var workerVals []chan uint8
-	var haloChans []chan uint8
+	var upHalos, downHalos []chan uint8
 	var workerNextTurns []chan uint8
+	var workerMoves []chan rowMove
 	for i := 0; i < p.threads; i++ {
 		workerCommands = append(workerCommands, make(chan workerCommand))
+		workerMoves = append(workerMoves, make(chan rowMove))
 		workerVals = append(workerVals, make(chan uint8))
-		haloChans = append(haloChans, make(chan uint8))
+		upHalos = append(upHalos, make(chan uint8, p.imageWidth))
+		downHalos = append(downHalos, make(chan uint8, p.imageWidth))
 		workerNextTurns = append(workerNextTurns, make(chan uint8))
 	}
 	dChans.workerCommands = workerCommands
 	dChans.workerVals = workerVals
 	dChans.workerNextTurns = workerNextTurns
+	dChans.workerMoves = workerMoves
 
-	workerHeight := p.imageHeight/p.threads + 1
-	numBigWorkers := p.imageHeight % p.threads
+	sections := staticSections(p)
 	for i := 0; i < p.threads; i++ {
-		if i == numBigWorkers {
-			workerHeight--
+		// Each worker sends its top row up and its bottom row down, and the neighbours on either side receive them.
+		halos := workerHalos{
+			sendTop:    upHalos[i],
+			sendBottom: downHalos[i],
+			top:        downHalos[(i+p.threads-1)%p.threads],
+			bottom:     upHalos[(i+1)%p.threads],
 		}
-		go worker(p, workerVals[i], haloChans[i], haloChans[(i+1)%p.threads], workerNextTurns[i], aliveWorkers, workerCommands[i], workerHeight, i)
+		go worker(p, workerVals[i], halos, workerNextTurns[i], reports, heatMaps, workerCommands[i], workerMoves[i], sections[i], sections.height(i), i)
 	}
 
 	aliveCells := make(chan []cell)
@@ -146,6 +272,20 @@ func gameOfLife(p golParams, keyChan <-chan rune) []cell {
 // main is the function called when starting Game of Life with 'make gol'
 // Do not edit until Stage 2.
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "plot":
+			plotCommand(os.Args[2:])
+			return
+		case "replay":
+			replayCommand(os.Args[2:])
+			return
+		case "diff":
+			diffCommand(os.Args[2:])
+			return
+		}
+	}
+
 	var params golParams
 	keyChan := make(chan rune)
 
@@ -167,12 +307,172 @@ func main() {
 		512,
 		"Specify the height of the image. Defaults to 512.")
 
+	var metricsAddr string
+	flag.StringVar(
+		&metricsAddr,
+		"metrics",
+		"",
+		"Specify an address, such as localhost:2112, to serve Prometheus metrics on /metrics. Off by default.")
+
+	var liveAddr string
+	flag.StringVar(
+		&liveAddr,
+		"live",
+		"",
+		"Specify an address, such as localhost:8080, to watch the board in a browser. Off by default.")
+
+	flag.StringVar(
+		&params.gifPath,
+		"gif",
+		"",
+		"Specify a file, such as out.gif, to record the run to as an animated GIF. Off by default.")
+
+	flag.IntVar(
+		&params.gifEvery,
+		"gif-every",
+		1,
+		"Specify how many turns apart the GIF frames are. Defaults to 1.")
+
+	flag.IntVar(
+		&params.gifScale,
+		"gif-scale",
+		1,
+		"Specify how many pixels wide each cell is in the GIF. Defaults to 1.")
+
+	flag.StringVar(
+		&params.outFormat,
+		"out-format",
+		formatPgm,
+		"Specify the format to save images in: pgm, pbm or png. Defaults to pgm.")
+
+	flag.BoolVar(
+		&params.heatMap,
+		"heatmap",
+		false,
+		"Specify whether to save heat maps of how long each cell was alive and how often it flipped. Off by default.")
+
+	flag.IntVar(
+		&params.statsEvery,
+		"stats-every",
+		0,
+		"Specify how many turns apart to save the population, births, deaths and bounding box. Off by default.")
+
+	flag.StringVar(
+		&params.statsFormat,
+		"stats-format",
+		statsCsv,
+		"Specify the format to save the population history in: csv or jsonl. Defaults to csv.")
+
+	flag.BoolVar(
+		&params.bounded,
+		"bounded",
+		false,
+		"Specify whether cells beyond the edges are always dead, instead of the board wrapping around. Off by default.")
+
+	flag.BoolVar(
+		&params.trackShips,
+		"track",
+		false,
+		"Specify whether to report the gliders and spaceships on the board and how they move. Off by default.")
+
+	flag.IntVar(
+		&params.historyTurns,
+		"history",
+		100,
+		"Specify how many turns to remember for stepping back with 'b' while paused. Resuming from an earlier turn only branches when no heat maps, statistics, GIF or ships are being recorded. Defaults to 100.")
+
+	var historyMB int
+	flag.IntVar(
+		&historyMB,
+		"history-mb",
+		64,
+		"Specify the most memory, in megabytes, to use for remembering turns. Defaults to 64.")
+
+	flag.StringVar(
+		&params.recordPath,
+		"record",
+		"",
+		"Specify a file, such as run.golrec, to record the run to. Off by default.")
+
+	flag.StringVar(
+		&params.cpuProfile,
+		"cpuprofile",
+		"",
+		"Specify a file name, such as cpu.prof, to write a CPU profile of the whole run to in out/. Off by default.")
+
+	flag.StringVar(
+		&params.memProfile,
+		"memprofile",
+		"",
+		"Specify a file name, such as mem.prof, to write a memory profile of the whole run to in out/. Off by default.")
+
+	flag.StringVar(
+		&params.executionTrace,
+		"trace",
+		"",
+		"Specify a file name, such as trace.out, to write an execution trace of the whole run to in out/. Off by default.")
+
+	flag.IntVar(
+		&params.rebalanceEvery,
+		"rebalance",
+		0,
+		"Specify how many turns apart to move rows between the workers to even out how long they take. Off by default.")
+
+	flag.BoolVar(
+		&params.timing,
+		"timing",
+		false,
+		"Specify whether to print how long each worker spent computing and waiting, and how unevenly the work was shared. Off by default.")
+
+	flag.StringVar(
+		&params.timeline,
+		"timeline",
+		"",
+		"Specify a file name, such as timeline.json, to write a timeline of every worker's turns to in out/, for chrome://tracing. Off by default.")
+
+	var pprofAddr string
+	flag.StringVar(
+		&pprofAddr,
+		"pprof",
+		"",
+		"Specify an address, such as localhost:6060, to serve profiles of the running program on /debug/pprof/. Off by default.")
+
 	flag.Parse()
 
+	params.historyBytes = historyMB << 20
+
+	if !validOutFormat(params.outFormat) {
+		fmt.Println("Unknown output format", params.outFormat)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if params.statsFormat != statsCsv && params.statsFormat != statsJsonl {
+		fmt.Println("Unknown stats format", params.statsFormat)
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	params.turns = 1000000000000
 
+	if metricsAddr != "" {
+		startMetricsServer(metricsAddr)
+	}
+	if pprofAddr != "" {
+		startPprofServer(pprofAddr)
+	}
+
+	var events chan Event
+	if liveAddr != "" {
+		// Every cell that flips is an event of its own, so the chan is buffered
+		// to let the distributor send a turn's worth without waiting on the live view each time.
+		events = make(chan Event, eventsBuffer)
+		startLiveServer(liveAddr, params, keyChan, events)
+	}
+
 	startControlServer(params)
 	go getKeyboardCommand(keyChan)
-	gameOfLife(params, keyChan)
+	alive := gameOfLife(params, keyChan, events)
 	StopControlServer()
+
+	fmt.Print(formatCensus(census(alive, params.imageWidth, params.imageHeight)))
 }
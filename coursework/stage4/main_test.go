@@ -3,323 +3,324 @@ package main
 import (
 	"os"
 	"testing"
-
-	"github.com/stretchr/testify/assert"
 )
 
+type args struct {
+	p             golParams
+	expectedAlive []cell
+}
+
+// tests are the runs that Test checks. TestGolden checks every thread count in them against its golden files too.
+var tests = []struct {
+	name string
+	args args
+}{
+	{"16x16x2-0", args{
+		p: golParams{
+			turns:       0,
+			threads:     2,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 4, y: 5},
+			{x: 5, y: 6},
+			{x: 3, y: 7},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+		},
+	}},
+
+	{"16x16x4-0", args{
+		p: golParams{
+			turns:       0,
+			threads:     4,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 4, y: 5},
+			{x: 5, y: 6},
+			{x: 3, y: 7},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+		},
+	}},
+
+	{"16x16x6-0", args{
+		p: golParams{
+			turns:       0,
+			threads:     6,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 4, y: 5},
+			{x: 5, y: 6},
+			{x: 3, y: 7},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+		},
+	}},
+
+	{"16x16x8-0", args{
+		p: golParams{
+			turns:       0,
+			threads:     8,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 4, y: 5},
+			{x: 5, y: 6},
+			{x: 3, y: 7},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+		},
+	}},
+
+	{"16x16x10-0", args{
+		p: golParams{
+			turns:       0,
+			threads:     10,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 4, y: 5},
+			{x: 5, y: 6},
+			{x: 3, y: 7},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+		},
+	}},
+
+	{"16x16x12-0", args{
+		p: golParams{
+			turns:       0,
+			threads:     12,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 4, y: 5},
+			{x: 5, y: 6},
+			{x: 3, y: 7},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+		},
+	}},
+
+	{"16x16x2-1", args{
+		p: golParams{
+			turns:       1,
+			threads:     2,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 3, y: 6},
+			{x: 5, y: 6},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+			{x: 4, y: 8},
+		},
+	}},
+
+	{"16x16x4-1", args{
+		p: golParams{
+			turns:       1,
+			threads:     4,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 3, y: 6},
+			{x: 5, y: 6},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+			{x: 4, y: 8},
+		},
+	}},
+
+	{"16x16x6-1", args{
+		p: golParams{
+			turns:       1,
+			threads:     6,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 3, y: 6},
+			{x: 5, y: 6},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+			{x: 4, y: 8},
+		},
+	}},
+
+	{"16x16x8-1", args{
+		p: golParams{
+			turns:       1,
+			threads:     8,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 3, y: 6},
+			{x: 5, y: 6},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+			{x: 4, y: 8},
+		},
+	}},
+
+	{"16x16x10-1", args{
+		p: golParams{
+			turns:       1,
+			threads:     10,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 3, y: 6},
+			{x: 5, y: 6},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+			{x: 4, y: 8},
+		},
+	}},
+
+	{"16x16x12-1", args{
+		p: golParams{
+			turns:       1,
+			threads:     12,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 3, y: 6},
+			{x: 5, y: 6},
+			{x: 4, y: 7},
+			{x: 5, y: 7},
+			{x: 4, y: 8},
+		},
+	}},
+
+	{"16x16x2-100", args{
+		p: golParams{
+			turns:       100,
+			threads:     2,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 12, y: 0},
+			{x: 13, y: 0},
+			{x: 14, y: 0},
+			{x: 13, y: 14},
+			{x: 14, y: 15},
+		},
+	}},
+
+	{"16x16x4-100", args{
+		p: golParams{
+			turns:       100,
+			threads:     4,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 12, y: 0},
+			{x: 13, y: 0},
+			{x: 14, y: 0},
+			{x: 13, y: 14},
+			{x: 14, y: 15},
+		},
+	}},
+
+	{"16x16x6-100", args{
+		p: golParams{
+			turns:       100,
+			threads:     6,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 12, y: 0},
+			{x: 13, y: 0},
+			{x: 14, y: 0},
+			{x: 13, y: 14},
+			{x: 14, y: 15},
+		},
+	}},
+
+	{"16x16x8-100", args{
+		p: golParams{
+			turns:       100,
+			threads:     8,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 12, y: 0},
+			{x: 13, y: 0},
+			{x: 14, y: 0},
+			{x: 13, y: 14},
+			{x: 14, y: 15},
+		},
+	}},
+
+	{"16x16x10-100", args{
+		p: golParams{
+			turns:       100,
+			threads:     10,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 12, y: 0},
+			{x: 13, y: 0},
+			{x: 14, y: 0},
+			{x: 13, y: 14},
+			{x: 14, y: 15},
+		},
+	}},
+
+	{"16x16x12-100", args{
+		p: golParams{
+			turns:       100,
+			threads:     12,
+			imageWidth:  16,
+			imageHeight: 16,
+		},
+		expectedAlive: []cell{
+			{x: 12, y: 0},
+			{x: 13, y: 0},
+			{x: 14, y: 0},
+			{x: 13, y: 14},
+			{x: 14, y: 15},
+		},
+	}},
+
+	// Special test to be used to generate traces - not a real test
+	//{"trace", args{
+	//	p: golParams{
+	//		turns:       10,
+	//		threads:     4,
+	//		imageWidth:  64,
+	//		imageHeight: 64,
+	//	},
+	//}},
+}
+
 func Test(t *testing.T) {
-	type args struct {
-		p             golParams
-		expectedAlive []cell
-	}
-	tests := []struct {
-		name string
-		args args
-	}{
-		{"16x16x2-0", args{
-			p: golParams{
-				turns:       0,
-				threads:     2,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 4, y: 5},
-				{x: 5, y: 6},
-				{x: 3, y: 7},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-			},
-		}},
-
-		{"16x16x4-0", args{
-			p: golParams{
-				turns:       0,
-				threads:     4,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 4, y: 5},
-				{x: 5, y: 6},
-				{x: 3, y: 7},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-			},
-		}},
-
-		{"16x16x6-0", args{
-			p: golParams{
-				turns:       0,
-				threads:     6,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 4, y: 5},
-				{x: 5, y: 6},
-				{x: 3, y: 7},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-			},
-		}},
-
-		{"16x16x8-0", args{
-			p: golParams{
-				turns:       0,
-				threads:     8,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 4, y: 5},
-				{x: 5, y: 6},
-				{x: 3, y: 7},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-			},
-		}},
-
-		{"16x16x10-0", args{
-			p: golParams{
-				turns:       0,
-				threads:     10,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 4, y: 5},
-				{x: 5, y: 6},
-				{x: 3, y: 7},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-			},
-		}},
-
-		{"16x16x12-0", args{
-			p: golParams{
-				turns:       0,
-				threads:     12,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 4, y: 5},
-				{x: 5, y: 6},
-				{x: 3, y: 7},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-			},
-		}},
-
-		{"16x16x2-1", args{
-			p: golParams{
-				turns:       1,
-				threads:     2,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 3, y: 6},
-				{x: 5, y: 6},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-				{x: 4, y: 8},
-			},
-		}},
-
-		{"16x16x4-1", args{
-			p: golParams{
-				turns:       1,
-				threads:     4,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 3, y: 6},
-				{x: 5, y: 6},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-				{x: 4, y: 8},
-			},
-		}},
-
-		{"16x16x6-1", args{
-			p: golParams{
-				turns:       1,
-				threads:     6,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 3, y: 6},
-				{x: 5, y: 6},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-				{x: 4, y: 8},
-			},
-		}},
-
-		{"16x16x8-1", args{
-			p: golParams{
-				turns:       1,
-				threads:     8,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 3, y: 6},
-				{x: 5, y: 6},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-				{x: 4, y: 8},
-			},
-		}},
-
-		{"16x16x10-1", args{
-			p: golParams{
-				turns:       1,
-				threads:     10,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 3, y: 6},
-				{x: 5, y: 6},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-				{x: 4, y: 8},
-			},
-		}},
-
-		{"16x16x12-1", args{
-			p: golParams{
-				turns:       1,
-				threads:     12,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 3, y: 6},
-				{x: 5, y: 6},
-				{x: 4, y: 7},
-				{x: 5, y: 7},
-				{x: 4, y: 8},
-			},
-		}},
-
-		{"16x16x2-100", args{
-			p: golParams{
-				turns:       100,
-				threads:     2,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 12, y: 0},
-				{x: 13, y: 0},
-				{x: 14, y: 0},
-				{x: 13, y: 14},
-				{x: 14, y: 15},
-			},
-		}},
-
-		{"16x16x4-100", args{
-			p: golParams{
-				turns:       100,
-				threads:     4,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 12, y: 0},
-				{x: 13, y: 0},
-				{x: 14, y: 0},
-				{x: 13, y: 14},
-				{x: 14, y: 15},
-			},
-		}},
-
-		{"16x16x6-100", args{
-			p: golParams{
-				turns:       100,
-				threads:     6,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 12, y: 0},
-				{x: 13, y: 0},
-				{x: 14, y: 0},
-				{x: 13, y: 14},
-				{x: 14, y: 15},
-			},
-		}},
-
-		{"16x16x8-100", args{
-			p: golParams{
-				turns:       100,
-				threads:     8,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 12, y: 0},
-				{x: 13, y: 0},
-				{x: 14, y: 0},
-				{x: 13, y: 14},
-				{x: 14, y: 15},
-			},
-		}},
-
-		{"16x16x10-100", args{
-			p: golParams{
-				turns:       100,
-				threads:     10,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 12, y: 0},
-				{x: 13, y: 0},
-				{x: 14, y: 0},
-				{x: 13, y: 14},
-				{x: 14, y: 15},
-			},
-		}},
-
-		{"16x16x12-100", args{
-			p: golParams{
-				turns:       100,
-				threads:     12,
-				imageWidth:  16,
-				imageHeight: 16,
-			},
-			expectedAlive: []cell{
-				{x: 12, y: 0},
-				{x: 13, y: 0},
-				{x: 14, y: 0},
-				{x: 13, y: 14},
-				{x: 14, y: 15},
-			},
-		}},
-
-		// Special test to be used to generate traces - not a real test
-		//{"trace", args{
-		//	p: golParams{
-		//		turns:       10,
-		//		threads:     4,
-		//		imageWidth:  64,
-		//		imageHeight: 64,
-		//	},
-		//}},
-	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			alive := gameOfLife(test.args.p, nil)
+			alive := gameOfLife(test.args.p, nil, nil)
 			//fmt.Println("Ran test:", test.name)
 			if test.name != "trace" {
-				assert.ElementsMatch(t, alive, test.args.expectedAlive)
+				assertAlive(t, test.args.p, test.args.expectedAlive, alive)
 			}
 		})
 	}
@@ -456,7 +457,7 @@ func Benchmark(b *testing.B) {
 		os.Stdout = nil // Disable all program output apart from benchmark results
 		b.Run(bm.name, func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				gameOfLife(bm.p, nil)
+				gameOfLife(bm.p, nil, nil)
 				//fmt.Println("Ran bench:", bm.name)
 			}
 		})
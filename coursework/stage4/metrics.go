@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// golMetrics collects the figures served on /metrics.
+// It is shared by every run of gameOfLife, so counters keep growing from one run to the next.
+var golMetrics = newMetrics()
+
+// metrics is updated by the distributor, the workers and the io goroutine.
+type metrics struct {
+	mutex sync.Mutex
+
+	turns          int64
+	turnsPerSecond float64
+	alive          int64
+
+	// The turns per second are worked out over at least rateWindow.
+	rateWindow time.Duration
+	rateStart  time.Time
+	rateTurns  int64
+
+	workerCompute     map[int]time.Duration
+	workerHaloWait    map[int]time.Duration
+	workerChannelWait map[int]time.Duration
+
+	bytesRead    int64
+	bytesWritten int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		rateWindow:        time.Second,
+		workerCompute:     make(map[int]time.Duration),
+		workerHaloWait:    make(map[int]time.Duration),
+		workerChannelWait: make(map[int]time.Duration),
+	}
+}
+
+// startRun is called by the distributor once the image has been loaded.
+func (m *metrics) startRun(alive int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.alive = int64(alive)
+	m.turnsPerSecond = 0
+	m.rateStart = time.Now()
+	m.rateTurns = m.turns
+}
+
+// turnComplete is called by the distributor after every turn.
+func (m *metrics) turnComplete(alive int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.turns++
+	m.alive = int64(alive)
+	if elapsed := time.Since(m.rateStart); elapsed >= m.rateWindow {
+		m.turnsPerSecond = float64(m.turns-m.rateTurns) / elapsed.Seconds()
+		m.rateStart = time.Now()
+		m.rateTurns = m.turns
+	}
+}
+
+// workerTurn is called by each worker with the time it spent calculating a turn, waiting for halos
+// and waiting on the distributor.
+func (m *metrics) workerTurn(worker int, timing workerTiming) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.workerCompute[worker] += timing.compute
+	m.workerHaloWait[worker] += timing.halo
+	m.workerChannelWait[worker] += timing.wait
+}
+
+// read and written are called by the io goroutine with the size of each file it reads or writes.
+func (m *metrics) read(bytes int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bytesRead += int64(bytes)
+}
+
+func (m *metrics) written(bytes int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bytesWritten += int64(bytes)
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format.
+func (m *metrics) writeTo(w io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	family := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	}
+	perWorker := func(name string, times map[int]time.Duration) {
+		var workers []int
+		for worker := range times {
+			workers = append(workers, worker)
+		}
+		sort.Ints(workers)
+		for _, worker := range workers {
+			fmt.Fprintf(w, "%s{worker=\"%d\"} %g\n", name, worker, times[worker].Seconds())
+		}
+	}
+
+	family("gol_turns_completed_total", "counter", "Turns completed by the workers.")
+	fmt.Fprintf(w, "gol_turns_completed_total %d\n", m.turns)
+	family("gol_turns_per_second", "gauge", "Turns completed per second, over the last second or more.")
+	fmt.Fprintf(w, "gol_turns_per_second %g\n", m.turnsPerSecond)
+	family("gol_alive_cells", "gauge", "Cells alive after the last completed turn.")
+	fmt.Fprintf(w, "gol_alive_cells %d\n", m.alive)
+	family("gol_worker_compute_seconds_total", "counter", "Time each worker has spent calculating turns.")
+	perWorker("gol_worker_compute_seconds_total", m.workerCompute)
+	family("gol_worker_halo_wait_seconds_total", "counter", "Time each worker has spent exchanging halos with its neighbours.")
+	perWorker("gol_worker_halo_wait_seconds_total", m.workerHaloWait)
+	family("gol_worker_channel_wait_seconds_total", "counter", "Time each worker has spent waiting to send its reports to the distributor and to be sent turns.")
+	perWorker("gol_worker_channel_wait_seconds_total", m.workerChannelWait)
+	family("gol_io_read_bytes_total", "counter", "Bytes read from image files by the io goroutine.")
+	fmt.Fprintf(w, "gol_io_read_bytes_total %d\n", m.bytesRead)
+	family("gol_io_written_bytes_total", "counter", "Bytes written to image files by the io goroutine.")
+	fmt.Fprintf(w, "gol_io_written_bytes_total %d\n", m.bytesWritten)
+}
+
+// ServeHTTP serves the metrics on /metrics.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeTo(w)
+}
+
+// startMetricsServer serves /metrics on the given address in the background.
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", golMetrics)
+	go func() {
+		check(http.ListenAndServe(addr, mux))
+	}()
+	fmt.Println("Metrics:", addr+"/metrics")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrapeMetrics fetches /metrics from the server and returns each sample's value, keyed by name and labels.
+// It fails the test if any line is not in the Prometheus text exposition format.
+func scrapeMetrics(t *testing.T, url string) map[string]float64 {
+	response, err := http.Get(url + "/metrics")
+	check(err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/plain; version=0.0.4", response.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(response.Body)
+	check(err)
+
+	commentLine := regexp.MustCompile(`^# (HELP [a-zA-Z_:][a-zA-Z0-9_:]* .+|TYPE [a-zA-Z_:][a-zA-Z0-9_:]* (counter|gauge|histogram|summary|untyped))$`)
+	sampleLine := regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*(\{[a-zA-Z_][a-zA-Z0-9_]*="[^"]*"(,[a-zA-Z_][a-zA-Z0-9_]*="[^"]*")*\})?) (\S+)$`)
+
+	samples := make(map[string]float64)
+	typed := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			assert.Regexp(t, commentLine, line)
+			if fields := strings.Fields(line); fields[1] == "TYPE" {
+				typed[fields[2]] = true
+			}
+			continue
+		}
+		match := sampleLine.FindStringSubmatch(line)
+		if !assert.NotNil(t, match, line) {
+			continue
+		}
+		name := strings.SplitN(match[1], "{", 2)[0]
+		assert.True(t, typed[name], "%s has no TYPE line before it", name)
+		value, err := strconv.ParseFloat(match[4], 64)
+		assert.NoError(t, err, line)
+		samples[match[1]] = value
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	p := golParams{
+		turns:       50,
+		threads:     4,
+		imageWidth:  64,
+		imageHeight: 64,
+	}
+	worlds := referenceWorlds(p, p.turns)
+	cleanOutput(t)
+
+	server := httptest.NewServer(golMetrics)
+	defer server.Close()
+
+	before := scrapeMetrics(t, server.URL)
+	gameOfLife(p, nil, nil)
+	after := scrapeMetrics(t, server.URL)
+
+	assert.Equal(t, float64(p.turns), after["gol_turns_completed_total"]-before["gol_turns_completed_total"])
+	assert.Equal(t, float64(len(aliveCells(worlds[p.turns]))), after["gol_alive_cells"])
+	assert.Contains(t, after, "gol_turns_per_second")
+	for i := 0; i < p.threads; i++ {
+		worker := `{worker="` + strconv.Itoa(i) + `"}`
+		assert.True(t, after["gol_worker_compute_seconds_total"+worker] > before["gol_worker_compute_seconds_total"+worker])
+		assert.Contains(t, after, "gol_worker_halo_wait_seconds_total"+worker)
+		assert.Contains(t, after, "gol_worker_channel_wait_seconds_total"+worker)
+	}
+
+	// The 64x64 image and the final image are both 64*64 bytes with a 13 byte header.
+	assert.Equal(t, float64(64*64+13), after["gol_io_read_bytes_total"]-before["gol_io_read_bytes_total"])
+	assert.Equal(t, float64(64*64+13), after["gol_io_written_bytes_total"]-before["gol_io_written_bytes_total"])
+}
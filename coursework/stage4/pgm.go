@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io/ioutil"
 	"os"
 	"strconv"
-	"strings"
 )
 
 func check(e error) {
@@ -14,84 +18,263 @@ func check(e error) {
 	}
 }
 
-// writePgmImage receives an array of bytes and writes it to a pgm file.
-// Note that this function is incomplete. Use the commented-out for loop to receive data from the distributor.
+// Output formats that can be selected with -out-format.
+const (
+	formatPgm = "pgm"
+	formatPbm = "pbm"
+	formatPng = "png"
+)
+
+// outFormat returns the format images are saved in, which is pgm unless another was asked for.
+func outFormat(p golParams) string {
+	if p.outFormat == "" {
+		return formatPgm
+	}
+	return p.outFormat
+}
+
+// validOutFormat reports whether images can be saved in the given format.
+func validOutFormat(format string) bool {
+	return format == formatPgm || format == formatPbm || format == formatPng
+}
+
+// writePgmImage receives the world from the distributor and writes it to an image file in out/.
+// The file is a pgm unless another format was selected with -out-format.
 func writePgmImage(p golParams, i ioChans) {
 	_ = os.Mkdir("out", os.ModePerm)
 
 	filename := <-i.distributor.filename
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	check(ioError)
-	defer file.Close()
-
-	_, _ = file.WriteString("P5\n")
-	// _, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(p.imageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(p.imageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
 
 	world := make([][]byte, p.imageHeight)
 	for i := range world {
 		world[i] = make([]byte, p.imageWidth)
 	}
 
-	// TODO: write a for-loop to receive the world from the distributor when outputting.
 	for y := 0; y < p.imageHeight; y++ {
 		for x := 0; x < p.imageWidth; x++ {
-			world[x][y] = <-i.distributor.outputVal
+			world[y][x] = <-i.distributor.outputVal
 		}
 	}
 
-	for y := 0; y < p.imageHeight; y++ {
-		for x := 0; x < p.imageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			check(ioError)
-		}
-	}
+	data := encodeImage(outFormat(p), world)
+
+	file, ioError := os.Create("out/" + filename + "." + outFormat(p))
+	check(ioError)
+	defer file.Close()
+
+	_, ioError = file.Write(data)
+	check(ioError)
 
 	ioError = file.Sync()
 	check(ioError)
+	golMetrics.written(len(data))
 
 	fmt.Println("File", filename, "output done!")
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
+// encodeImage returns the contents of an image file for the world in the given format.
+func encodeImage(format string, world [][]byte) []byte {
+	height := len(world)
+	width := 0
+	if height > 0 {
+		width = len(world[0])
+	}
+
+	var buffer bytes.Buffer
+	switch format {
+	case formatPgm:
+		// P5: one byte per cell, 0 for dead and 255 for alive.
+		buffer.WriteString("P5\n" + strconv.Itoa(width) + " " + strconv.Itoa(height) + "\n" + strconv.Itoa(255) + "\n")
+		for y := range world {
+			buffer.Write(world[y])
+		}
+
+	case formatPbm:
+		// P4: one bit per cell, with each row padded to a whole byte.
+		// A set bit is black, so dead cells are 1 and alive cells are 0, as they look in the pgm.
+		buffer.WriteString("P4\n" + strconv.Itoa(width) + " " + strconv.Itoa(height) + "\n")
+		row := make([]byte, (width+7)/8)
+		for y := range world {
+			for i := range row {
+				row[i] = 0
+			}
+			for x, c := range world[y] {
+				if c == 0 {
+					row[x/8] |= 0x80 >> uint(x%8)
+				}
+			}
+			buffer.Write(row)
+		}
+
+	case formatPng:
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := range world {
+			copy(img.Pix[y*img.Stride:], world[y])
+		}
+		check(png.Encode(&buffer, img))
+
+	default:
+		panic("Unknown image format " + format)
+	}
+	return buffer.Bytes()
+}
+
+// readPgmImage opens an image file and sends its cells to the distributor byte by byte, in rows.
 func readPgmImage(p golParams, i ioChans) {
 	filename := <-i.distributor.filename
 	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
 	check(ioError)
+	golMetrics.read(len(data))
 
-	fields := strings.Fields(string(data))
+	world, ioError := decodeImage(data)
+	check(ioError)
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+	if len(world[0]) != p.imageWidth {
+		panic("Incorrect width")
+	}
+	if len(world) != p.imageHeight {
+		panic("Incorrect height")
 	}
 
-	width, _ := strconv.Atoi(fields[1])
-	if width != p.imageWidth {
-		panic("Incorrect width")
+	for y := range world {
+		for _, b := range world[y] {
+			i.distributor.inputVal <- b
+		}
 	}
 
-	height, _ := strconv.Atoi(fields[2])
-	if height != p.imageHeight {
-		panic("Incorrect height")
+	fmt.Println("File", filename, "input done!")
+}
+
+// maxImageCells is the most cells an image may have, so that a corrupt header cannot make the reader run out of memory.
+const maxImageCells = 1 << 26
+
+// decodeImage reads a pgm (P5), pbm (P4) or png image into a world, with 255 for alive cells and 0 for dead ones.
+// The format is worked out from the start of the file. Grey pixels are alive if they are closer to white than black.
+func decodeImage(data []byte) ([][]byte, error) {
+	if bytes.HasPrefix(data, []byte("\x89PNG")) {
+		config, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if config.Width <= 0 || config.Height <= 0 || config.Width > maxImageCells/config.Height {
+			return nil, errors.New("png image has an impossible size")
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		world := make([][]byte, bounds.Dy())
+		for y := range world {
+			world[y] = make([]byte, bounds.Dx())
+			for x := range world[y] {
+				if color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y >= 0x80 {
+					world[y][x] = 0xFF
+				}
+			}
+		}
+		return world, nil
 	}
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+	magic, fields, pixels, err := readPnmHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	width, height := fields[0], fields[1]
+	if width > maxImageCells/height {
+		return nil, errors.New("image has an impossible size")
 	}
 
-	image := []byte(fields[4])
+	var world [][]byte
+	switch magic {
+	case "P5":
+		if fields[2] != 255 {
+			return nil, errors.New("incorrect maxval/bit depth")
+		}
+		if len(pixels) < width*height {
+			return nil, errors.New("pgm image is too short")
+		}
+		world = make([][]byte, height)
+		for y := range world {
+			world[y] = make([]byte, width)
+			for x, pixel := range pixels[y*width : (y+1)*width] {
+				if pixel >= 0x80 {
+					world[y][x] = 0xFF
+				}
+			}
+		}
 
-	for _, b := range image {
-		i.distributor.inputVal <- b
+	case "P4":
+		rowBytes := (width + 7) / 8
+		if len(pixels) < rowBytes*height {
+			return nil, errors.New("pbm image is too short")
+		}
+		world = make([][]byte, height)
+		for y := range world {
+			world[y] = make([]byte, width)
+			row := pixels[y*rowBytes : (y+1)*rowBytes]
+			for x := range world[y] {
+				if row[x/8]&(0x80>>uint(x%8)) == 0 {
+					world[y][x] = 0xFF
+				}
+			}
+		}
 	}
+	return world, nil
+}
 
-	fmt.Println("File", filename, "input done!")
+// readPnmHeader splits a P4 or P5 file into its magic number, the numbers in its header and its pixels.
+// P5 headers have a width, height and maxval, P4 headers only a width and height.
+// Comments starting with '#' are skipped.
+func readPnmHeader(data []byte) (magic string, fields []int, pixels []byte, err error) {
+	if len(data) < 2 {
+		return "", nil, nil, errors.New("not a pgm or pbm file")
+	}
+	magic = string(data[:2])
+	count := 0
+	switch magic {
+	case "P5":
+		count = 3
+	case "P4":
+		count = 2
+	default:
+		return "", nil, nil, errors.New("not a pgm or pbm file")
+	}
+
+	i := 2
+	for len(fields) < count {
+		for i < len(data) && (isPnmSpace(data[i]) || data[i] == '#') {
+			if data[i] == '#' {
+				for i < len(data) && data[i] != '\n' {
+					i++
+				}
+			} else {
+				i++
+			}
+		}
+		start := i
+		for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+			i++
+		}
+		if start == i {
+			return "", nil, nil, errors.New("malformed header")
+		}
+		n, convErr := strconv.Atoi(string(data[start:i]))
+		if convErr != nil || n <= 0 {
+			return "", nil, nil, errors.New("malformed header")
+		}
+		fields = append(fields, n)
+	}
+
+	// Exactly one whitespace byte separates the header from the pixels.
+	if i >= len(data) || !isPnmSpace(data[i]) {
+		return "", nil, nil, errors.New("malformed header")
+	}
+	return magic, fields, data[i+1:], nil
+}
+
+func isPnmSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
 }
 
 func pgmIo(p golParams, i ioChans) {
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomWorld makes a world with roughly half of its cells alive.
+func randomWorld(width, height int, seed int64) [][]byte {
+	random := rand.New(rand.NewSource(seed))
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+		for x := range world[y] {
+			if random.Intn(2) == 1 {
+				world[y][x] = 0xFF
+			}
+		}
+	}
+	return world
+}
+
+func TestImageFormats(t *testing.T) {
+	// 13 is not a multiple of 8, so the pbm rows need padding.
+	sizes := [][2]int{{16, 16}, {13, 7}, {1, 1}, {512, 512}}
+
+	for _, format := range []string{formatPgm, formatPbm, formatPng} {
+		t.Run(format+" round trip", func(t *testing.T) {
+			for i, size := range sizes {
+				world := randomWorld(size[0], size[1], int64(i))
+				decoded, err := decodeImage(encodeImage(format, world))
+				assert.NoError(t, err)
+				assert.Equal(t, world, decoded, "%dx%d", size[0], size[1])
+			}
+		})
+	}
+
+	t.Run("pbm size", func(t *testing.T) {
+		world := randomWorld(512, 512, 0)
+		pgm := encodeImage(formatPgm, world)
+		pbm := encodeImage(formatPbm, world)
+		assert.Equal(t, 512*512/8+len("P4\n512 512\n"), len(pbm))
+		assert.InDelta(t, 8, float64(len(pgm))/float64(len(pbm)), 0.01)
+	})
+
+	t.Run("comments and whitespace", func(t *testing.T) {
+		world, err := decodeImage([]byte("P4 # a comment\n3\t# another\r\n2\n\x40\xA0"))
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{{0xFF, 0, 0xFF}, {0, 0xFF, 0}}, world)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, data := range []string{"", "P6\n1 1\n255\n\x00", "P5\n2 2\n255\n\x00", "P4\n8\n", "P5\n1 1 255"} {
+			_, err := decodeImage([]byte(data))
+			assert.Error(t, err, "%q", data)
+		}
+	})
+
+	t.Run("impossible sizes", func(t *testing.T) {
+		// These would need terabytes if the reader believed their headers.
+		for _, data := range []string{"P5\n99999999 99999999\n255\n\x00", "P4\n100000 100000\n\x00", "P5\n9999999999999999999999 1\n255\n\x00"} {
+			_, err := decodeImage([]byte(data))
+			assert.Error(t, err, "%q", data)
+		}
+
+		// A png header for a 100000x100000 greyscale image, and nothing else.
+		header := []byte("IHDR\x00\x01\x86\xA0\x00\x01\x86\xA0\x08\x00\x00\x00\x00")
+		var data bytes.Buffer
+		data.WriteString("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+		data.Write(header)
+		_ = binary.Write(&data, binary.BigEndian, crc32.ChecksumIEEE(header))
+		_, err := decodeImage(data.Bytes())
+		assert.EqualError(t, err, "png image has an impossible size")
+	})
+
+	t.Run("grey pixels", func(t *testing.T) {
+		world, err := decodeImage([]byte("P5\n4 1\n255\n\x00\x7F\x80\xFF"))
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{{0, 0, 0xFF, 0xFF}}, world)
+	})
+
+	t.Run("images", func(t *testing.T) {
+		for _, size := range []string{"16x16", "64x64", "512x512"} {
+			data, err := ioutil.ReadFile("images/" + size + ".pgm")
+			check(err)
+			world, err := decodeImage(data)
+			assert.NoError(t, err)
+			assert.Equal(t, data, encodeImage(formatPgm, world), size)
+		}
+	})
+}
+
+func TestOutFormat(t *testing.T) {
+	for _, format := range []string{formatPbm, formatPng} {
+		t.Run(format, func(t *testing.T) {
+			p := golParams{
+				turns:       10,
+				threads:     4,
+				imageWidth:  64,
+				imageHeight: 64,
+				outFormat:   format,
+			}
+			worlds := referenceWorlds(p, p.turns)
+			cleanOutput(t)
+
+			gameOfLife(p, nil, nil)
+
+			data, err := ioutil.ReadFile("out/64x64_10." + format)
+			check(err)
+			world, err := decodeImage(data)
+			assert.NoError(t, err)
+			assert.Equal(t, worlds[p.turns], world)
+		})
+	}
+}
+
+// FuzzDecodeImage checks that any file can be given to the image reader without it panicking or
+// running out of memory, and that whatever it reads is saved and read back the same in every format.
+// Run it with 'go test -run XXX -fuzz FuzzDecodeImage'. The seed corpus is the bundled images,
+// leaving out the ones over 64x64, which take the fuzzer seconds each.
+func FuzzDecodeImage(f *testing.F) {
+	paths, err := filepath.Glob("images/*.pgm")
+	check(err)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		check(err)
+		if len(data) <= 64*64+len("P5\n64 64\n255\n") {
+			f.Add(data)
+		}
+	}
+	world := randomWorld(13, 7, 0)
+	for _, format := range []string{formatPbm, formatPng} {
+		f.Add(encodeImage(format, world))
+	}
+	f.Add([]byte("P4 # a comment\n3\t# another\r\n2\n\x40\xA0"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		world, err := decodeImage(data)
+		if err != nil {
+			return
+		}
+		if len(world) == 0 || len(world[0]) == 0 || len(world[0])*len(world) > maxImageCells {
+			t.Fatalf("read a %dx%d image", boardWidth(world), len(world))
+		}
+		for y := range world {
+			if len(world[y]) != len(world[0]) {
+				t.Fatalf("row %d is %d cells long, not %d", y, len(world[y]), len(world[0]))
+			}
+			for x, c := range world[y] {
+				if c != 0 && c != 0xFF {
+					t.Fatalf("cell (%d, %d) is %d, neither alive nor dead", x, y, c)
+				}
+			}
+		}
+
+		for _, format := range []string{formatPgm, formatPbm, formatPng} {
+			decoded, err := decodeImage(encodeImage(format, world))
+			if err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+			if !golDiff(world, decoded).same() {
+				t.Fatalf("%s changed the image:\n%s", format, golDiff(world, decoded).report("read", "saved and read again"))
+			}
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// plotCommand prints the population curve from a history file as an ASCII chart.
+// It is run with 'gameoflife plot [-width W] [-height H] out/512x512_stats.csv'.
+func plotCommand(args []string) {
+	flags := flag.NewFlagSet("plot", flag.ExitOnError)
+	width := flags.Int("width", 72, "Specify the width of the chart in characters. Defaults to 72.")
+	height := flags.Int("height", 20, "Specify the height of the chart in lines. Defaults to 20.")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 || *width < 2 || *height < 2 {
+		fmt.Println("Usage: gameoflife plot [-width W] [-height H] <stats file>")
+		os.Exit(2)
+	}
+
+	rows, err := readStats(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Print(plotPopulation(rows, *width, *height))
+}
+
+// plotPopulation draws the population after each turn in the rows, with turns along the bottom.
+// If there are more rows than columns, rows are picked evenly from the whole run.
+func plotPopulation(rows []statsRow, width, height int) string {
+	columns := width
+	if len(rows) < columns {
+		columns = len(rows)
+	}
+	picked := make([]statsRow, columns)
+	for i := range picked {
+		if columns == 1 {
+			picked[i] = rows[0]
+		} else {
+			picked[i] = rows[i*(len(rows)-1)/(columns-1)]
+		}
+	}
+
+	min, max := picked[0].Population, picked[0].Population
+	for _, row := range picked {
+		if row.Population < min {
+			min = row.Population
+		}
+		if row.Population > max {
+			max = row.Population
+		}
+	}
+
+	grid := make([][]byte, height)
+	for i := range grid {
+		grid[i] = []byte(strings.Repeat(" ", columns))
+	}
+	for x, row := range picked {
+		level := 0
+		if max > min {
+			level = ((row.Population-min)*(height-1) + (max-min)/2) / (max - min)
+		}
+		grid[height-1-level][x] = '*'
+	}
+
+	maxLabel, minLabel := strconv.Itoa(max), strconv.Itoa(min)
+	labelWidth := len(maxLabel)
+	if len(minLabel) > labelWidth {
+		labelWidth = len(minLabel)
+	}
+
+	var chart strings.Builder
+	for i, line := range grid {
+		label := ""
+		switch i {
+		case 0:
+			label = maxLabel
+		case height - 1:
+			label = minLabel
+		}
+		chart.WriteString(strings.Repeat(" ", labelWidth-len(label)) + label + " |" + strings.TrimRight(string(line), " ") + "\n")
+	}
+	chart.WriteString(strings.Repeat(" ", labelWidth) + " +" + strings.Repeat("-", columns) + "\n")
+
+	first, last := strconv.Itoa(picked[0].Turn), strconv.Itoa(picked[columns-1].Turn)
+	gap := columns - len(first) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	chart.WriteString(strings.Repeat(" ", labelWidth+2) + first + strings.Repeat(" ", gap) + last + "\n")
+	return chart.String()
+}
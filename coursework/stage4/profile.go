@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+)
+
+// profiles writes the profiles asked for with -cpuprofile, -memprofile and -trace to out/, next to the snapshots.
+// It also starts and stops the CPU profiles that 'c' takes of part of a run.
+type profiles struct {
+	p golParams
+
+	// cpu is the CPU profile being written, if there is one. Only one can run at a time.
+	// wholeRun is true if it is the one from -cpuprofile, which runs until the end.
+	cpu      *os.File
+	wholeRun bool
+
+	trace *os.File
+}
+
+// startProfiles starts the CPU profile and the execution trace of the whole run, if they were asked for.
+// A profile that cannot be started is reported, and the run goes on without it.
+func startProfiles(p golParams) *profiles {
+	pr := &profiles{p: p}
+	if p.cpuProfile != "" {
+		pr.startCPU(filepath.Join("out", p.cpuProfile))
+		pr.wholeRun = pr.cpu != nil
+	}
+	if p.executionTrace != "" {
+		file := createProfile(filepath.Join("out", p.executionTrace))
+		if err := trace.Start(file); err != nil {
+			fmt.Println("Cannot start the execution trace:", err)
+			discardProfile(file)
+		} else {
+			pr.trace = file
+		}
+	}
+	return pr
+}
+
+// createProfile creates the file for a profile in out/.
+func createProfile(path string) *os.File {
+	_ = os.Mkdir("out", os.ModePerm)
+	file, err := os.Create(path)
+	check(err)
+	return file
+}
+
+// discardProfile removes the file for a profile that could not be started.
+func discardProfile(file *os.File) {
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+}
+
+func (pr *profiles) startCPU(path string) {
+	file := createProfile(path)
+	// Only one CPU profile can run at a time, and 'go test -cpuprofile' or /debug/pprof/profile may have one running.
+	if err := pprof.StartCPUProfile(file); err != nil {
+		fmt.Println("Cannot start a CPU profile:", err)
+		discardProfile(file)
+		return
+	}
+	pr.cpu = file
+	fmt.Println("CPU profile started")
+}
+
+func (pr *profiles) stopCPU() {
+	pprof.StopCPUProfile()
+	check(pr.cpu.Close())
+	fmt.Println("CPU profile saved to", pr.cpu.Name())
+	pr.cpu = nil
+}
+
+// toggleCPU starts a CPU profile of the turns from now on, or stops the one started earlier.
+// The profile is saved as out/<width>x<height>_<turn it started>_cpu.prof.
+func (pr *profiles) toggleCPU(turns int) {
+	if pr.wholeRun {
+		fmt.Println("The CPU profile from -cpuprofile runs until the end")
+		return
+	}
+	if pr.cpu != nil {
+		pr.stopCPU()
+		return
+	}
+	pr.startCPU("out/" + strconv.Itoa(pr.p.imageWidth) + "x" + strconv.Itoa(pr.p.imageHeight) + "_" + strconv.Itoa(turns) + "_cpu.prof")
+}
+
+// finish stops any CPU profile and the execution trace, and writes the memory profile.
+func (pr *profiles) finish() {
+	if pr.cpu != nil {
+		pr.stopCPU()
+	}
+	if pr.trace != nil {
+		trace.Stop()
+		check(pr.trace.Close())
+		fmt.Println("Execution trace saved to", pr.trace.Name())
+	}
+	if pr.p.memProfile != "" {
+		file := createProfile(filepath.Join("out", pr.p.memProfile))
+		// The same as 'go test -memprofile': every allocation since the start, with what is in use after a GC.
+		runtime.GC()
+		check(pprof.Lookup("allocs").WriteTo(file, 0))
+		check(file.Close())
+		fmt.Println("Memory profile saved to", file.Name())
+	}
+}
+
+// pprofHandler serves the profiles of the running program under /debug/pprof/, for 'go tool pprof'.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	return mux
+}
+
+// startPprofServer serves the profiles on the address, such as localhost:6060, in the background.
+func startPprofServer(addr string) {
+	go func() {
+		check(http.ListenAndServe(addr, pprofHandler()))
+	}()
+	fmt.Println("Profiles:", addr+"/debug/pprof/")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// skipIfProfiling skips tests of CPU profiles when the test binary is already writing one, as with 'make cpuprofile'.
+func skipIfProfiling(t *testing.T) {
+	if err := pprof.StartCPUProfile(ioutil.Discard); err != nil {
+		t.Skip("A CPU profile is already running")
+	}
+	pprof.StopCPUProfile()
+}
+
+// assertProfile checks that the file in out/ holds a profile, which is gzipped.
+func assertProfile(t *testing.T, path string) {
+	data, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err) {
+		assert.True(t, bytes.HasPrefix(data, []byte{0x1f, 0x8b}), "%s is not a profile", path)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	skipIfProfiling(t)
+	p := golParams{
+		turns:       1000000000,
+		threads:     4,
+		imageWidth:  16,
+		imageHeight: 16,
+	}
+	cleanOutput(t)
+
+	t.Run("whole run", func(t *testing.T) {
+		p := p
+		p.turns = 20
+		p.cpuProfile, p.memProfile, p.executionTrace = "test_cpu.prof", "test_mem.prof", "test_trace.out"
+		out := captureStdout(func() { runScript(p, []scriptStep{{5, "c"}}, nil) })
+		assert.Contains(t, out, "The CPU profile from -cpuprofile runs until the end")
+
+		assertProfile(t, "out/test_cpu.prof")
+		assertProfile(t, "out/test_mem.prof")
+		data, err := ioutil.ReadFile("out/test_trace.out")
+		if assert.NoError(t, err) {
+			assert.True(t, bytes.HasPrefix(data, []byte("go 1.")), "not an execution trace")
+		}
+	})
+
+	t.Run("key", func(t *testing.T) {
+		out := captureStdout(func() { runScript(p, []scriptStep{{2, "c"}, {5, "c"}, {7, "c"}, {9, "q"}}, nil) })
+		assert.Equal(t, 2, strings.Count(out, "CPU profile started"), out)
+		assertProfile(t, "out/16x16_2_cpu.prof")
+		// The second profile is still running when the run ends, so it is saved then.
+		assertProfile(t, "out/16x16_7_cpu.prof")
+		_, err := os.Stat("out/16x16_5_cpu.prof")
+		assert.True(t, os.IsNotExist(err), "pressing 'c' to stop a profile does not start another")
+	})
+}
+
+func TestPprofHandler(t *testing.T) {
+	server := httptest.NewServer(pprofHandler())
+	defer server.Close()
+
+	for path, want := range map[string]string{
+		"/debug/pprof/":                  "goroutine",
+		"/debug/pprof/goroutine?debug=1": "goroutine profile",
+		"/debug/pprof/heap?debug=1":      "heap profile",
+		"/debug/pprof/cmdline":           "",
+		"/debug/pprof/symbol":            "num_symbols",
+	} {
+		response, err := http.Get(server.URL + path)
+		if !assert.NoError(t, err, path) {
+			continue
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		_ = response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode, path)
+		assert.Contains(t, string(body), want, path)
+	}
+}
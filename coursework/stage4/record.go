@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A recording is a gzip stream holding recordMagic, the width and height of the board,
+// and then a series of entries, each starting with its kind:
+//
+//	recordKeyframe, turn, the whole board with one bit per cell and each row padded to a whole byte
+//	recordTurn, turn, the number of flipped cells, and the flipped cells
+//	recordEnd
+//
+// Numbers are unsigned varints. Each flipped cell is given by its index y*width+x, and the
+// indices are in order and stored as the difference from the one before, so they stay small.
+// A keyframe is written at the start, and whenever the world is reset or a new run branches from an earlier turn.
+const recordMagic = "GOLREC1\n"
+
+// Kinds of entry in a recording.
+const (
+	recordKeyframe byte = 'K'
+	recordTurn     byte = 'T'
+	recordEnd      byte = 'E'
+)
+
+// recordBuffer is how many entries may wait to be written before the distributor has to wait.
+const recordBuffer = 64
+
+// recordEntry is a keyframe or the flips of a turn.
+type recordEntry struct {
+	kind    byte
+	turn    int
+	world   [][]byte
+	flipped [][]cell
+}
+
+// recorder writes the run to p.recordPath in the background.
+// Unlike the GIF, nothing may be dropped, so the distributor waits if the writer falls far behind.
+type recorder struct {
+	p       golParams
+	entries chan recordEntry
+	done    chan bool
+}
+
+// startRecording starts recording the run. It returns nil if no recording was asked for.
+func startRecording(p golParams) *recorder {
+	if p.recordPath == "" {
+		return nil
+	}
+	r := &recorder{p: p, entries: make(chan recordEntry, recordBuffer), done: make(chan bool)}
+	go writeRecording(p, r.entries, r.done)
+	return r
+}
+
+// keyframe records the whole world after the given turn.
+func (r *recorder) keyframe(world [][]byte, turns int) {
+	if r == nil {
+		return
+	}
+	copied := make([][]byte, len(world))
+	for y := range world {
+		copied[y] = append([]byte(nil), world[y]...)
+	}
+	r.entries <- recordEntry{kind: recordKeyframe, turn: turns, world: copied}
+}
+
+// turnComplete records the cells each worker flipped in the turn.
+func (r *recorder) turnComplete(turns int, flipped [][]cell) {
+	if r == nil {
+		return
+	}
+	r.entries <- recordEntry{kind: recordTurn, turn: turns, flipped: flipped}
+}
+
+// finish waits for everything to be written.
+func (r *recorder) finish() {
+	if r == nil {
+		return
+	}
+	close(r.entries)
+	<-r.done
+}
+
+// writeRecording encodes the entries as they arrive.
+func writeRecording(p golParams, entries <-chan recordEntry, done chan<- bool) {
+	file, ioError := os.Create(p.recordPath)
+	check(ioError)
+	buffered := bufio.NewWriter(file)
+	compressed := gzip.NewWriter(buffered)
+	w := bufio.NewWriter(compressed)
+
+	number := make([]byte, binary.MaxVarintLen64)
+	writeNumber := func(n int) {
+		_, _ = w.Write(number[:binary.PutUvarint(number, uint64(n))])
+	}
+
+	_, _ = w.WriteString(recordMagic)
+	writeNumber(p.imageWidth)
+	writeNumber(p.imageHeight)
+
+	var indices []int
+	for entry := range entries {
+		_ = w.WriteByte(entry.kind)
+		writeNumber(entry.turn)
+		switch entry.kind {
+		case recordKeyframe:
+			for y := range entry.world {
+				_, _ = w.Write(packRow(entry.world[y]))
+			}
+		case recordTurn:
+			indices = indices[:0]
+			for _, cells := range entry.flipped {
+				for _, c := range cells {
+					indices = append(indices, c.y*p.imageWidth+c.x)
+				}
+			}
+			sort.Ints(indices)
+			writeNumber(len(indices))
+			previous := 0
+			for _, index := range indices {
+				writeNumber(index - previous)
+				previous = index
+			}
+		}
+	}
+	_ = w.WriteByte(recordEnd)
+
+	check(w.Flush())
+	check(compressed.Close())
+	check(buffered.Flush())
+	check(file.Close())
+	fmt.Println("File", p.recordPath, "output done!")
+	done <- true
+}
+
+// packRow packs a row of the world into bits, with the first cell in the top bit of the first byte.
+func packRow(row []byte) []byte {
+	packed := make([]byte, (len(row)+7)/8)
+	for x, c := range row {
+		if c != 0 {
+			packed[x/8] |= 0x80 >> uint(x%8)
+		}
+	}
+	return packed
+}
+
+// replayer reads a recording back, one turn at a time.
+type replayer struct {
+	r      *bufio.Reader
+	width  int
+	height int
+
+	// world is the board after turn.
+	world [][]byte
+	turn  int
+}
+
+// openRecording reads the header of a recording. The world is empty until the first call to next.
+func openRecording(r io.Reader) (*replayer, error) {
+	compressed, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	rp := &replayer{r: bufio.NewReader(compressed)}
+
+	magic := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(rp.r, magic); err != nil || string(magic) != recordMagic {
+		return nil, errors.New("not a recording")
+	}
+	if rp.width, err = rp.number(); err != nil {
+		return nil, err
+	}
+	if rp.height, err = rp.number(); err != nil {
+		return nil, err
+	}
+	if rp.width <= 0 || rp.height <= 0 || rp.width*rp.height > 1<<28 {
+		return nil, errors.New("recording has an impossible size")
+	}
+
+	rp.world = make([][]byte, rp.height)
+	for y := range rp.world {
+		rp.world[y] = make([]byte, rp.width)
+	}
+	return rp, nil
+}
+
+func (rp *replayer) number() (int, error) {
+	n, err := binary.ReadUvarint(rp.r)
+	if err != nil {
+		return 0, err
+	}
+	if n > 1<<31 {
+		return 0, errors.New("number too large in recording")
+	}
+	return int(n), nil
+}
+
+// next moves on to the next entry. It returns io.EOF after the last one.
+func (rp *replayer) next() error {
+	kind, err := rp.r.ReadByte()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if kind == recordEnd {
+		return io.EOF
+	}
+	turn, err := rp.number()
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case recordKeyframe:
+		packed := make([]byte, (rp.width+7)/8)
+		for y := range rp.world {
+			if _, err := io.ReadFull(rp.r, packed); err != nil {
+				return err
+			}
+			for x := range rp.world[y] {
+				rp.world[y][x] = 0
+				if packed[x/8]&(0x80>>uint(x%8)) != 0 {
+					rp.world[y][x] = 0xFF
+				}
+			}
+		}
+
+	case recordTurn:
+		count, err := rp.number()
+		if err != nil {
+			return err
+		}
+		index := 0
+		for i := 0; i < count; i++ {
+			step, err := rp.number()
+			if err != nil {
+				return err
+			}
+			index += step
+			if index >= rp.width*rp.height {
+				return errors.New("flipped cell outside the board")
+			}
+			y, x := index/rp.width, index%rp.width
+			rp.world[y][x] = ^rp.world[y][x]
+		}
+
+	default:
+		return fmt.Errorf("unknown entry %q in recording", kind)
+	}
+	rp.turn = turn
+	return nil
+}
+
+// replayTo reads a recording until the first time the run reached the given turn, and returns the world then.
+func replayTo(path string, turn int) ([][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rp, err := openRecording(file)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if err := rp.next(); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("the run never reached turn %d", turn)
+			}
+			return nil, err
+		}
+		if rp.turn == turn {
+			return rp.world, nil
+		}
+	}
+}
+
+// replayCommand plays a recording back in the terminal, or saves one of its turns as an image.
+// It is run with 'gameoflife replay [-fps F] [-turn N -out file.pgm] run.golrec'.
+func replayCommand(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	fps := flags.Int("fps", 10, "Specify how many turns to show each second. Defaults to 10.")
+	turn := flags.Int("turn", -1, "Specify a turn to save as an image instead of playing the recording.")
+	out := flags.String("out", "", "Specify the image file to save the turn to, ending in .pgm, .pbm or .png.")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 || *fps < 1 || (*turn >= 0) != (*out != "") {
+		fmt.Println("Usage: gameoflife replay [-fps F] [-turn N -out image] <recording>")
+		os.Exit(2)
+	}
+
+	if *turn >= 0 {
+		format := strings.TrimPrefix(filepath.Ext(*out), ".")
+		if !validOutFormat(format) {
+			fmt.Println("Unknown output format", format)
+			os.Exit(2)
+		}
+		world, err := replayTo(flags.Arg(0), *turn)
+		if err == nil {
+			err = ioutil.WriteFile(*out, encodeImage(format, world), 0644)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("File", *out, "output done!")
+		return
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	check(err)
+	defer file.Close()
+	rp, err := openRecording(file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(*fps))
+	defer ticker.Stop()
+	for {
+		if err := rp.next(); err != nil {
+			if err != io.EOF {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		<-ticker.C
+		// Clear the terminal and draw from the top left.
+		fmt.Print("\x1b[H\x1b[2J" + drawWorld(rp.world, 160) + "Turn " + fmt.Sprint(rp.turn) + "\n")
+	}
+}
+
+// drawWorld draws the world as text no wider than the given number of characters.
+// Larger worlds are shrunk, with a character alive if any of the cells it covers are.
+func drawWorld(world [][]byte, maxWidth int) string {
+	scale := (len(world[0]) + maxWidth - 1) / maxWidth
+	var drawing strings.Builder
+	for y := 0; y < len(world); y += scale {
+		for x := 0; x < len(world[0]); x += scale {
+			alive := false
+			for dy := 0; dy < scale && y+dy < len(world); dy++ {
+				for dx := 0; dx < scale && x+dx < len(world[0]); dx++ {
+					alive = alive || world[y+dy][x+dx] != 0
+				}
+			}
+			if alive {
+				drawing.WriteByte('#')
+			} else {
+				drawing.WriteByte('.')
+			}
+		}
+		drawing.WriteByte('\n')
+	}
+	return drawing.String()
+}
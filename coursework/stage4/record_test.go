@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecording(t *testing.T) {
+	p := golParams{
+		turns:       100,
+		threads:     4,
+		imageWidth:  64,
+		imageHeight: 64,
+		recordPath:  filepath.Join(t.TempDir(), "run.golrec"),
+	}
+	worlds := referenceWorlds(p, p.turns)
+	cleanOutput(t)
+
+	t.Run("replay matches the simulation", func(t *testing.T) {
+		gameOfLife(p, nil, nil)
+
+		file, err := os.Open(p.recordPath)
+		check(err)
+		defer file.Close()
+		rp, err := openRecording(file)
+		check(err)
+		assert.Equal(t, p.imageWidth, rp.width)
+		assert.Equal(t, p.imageHeight, rp.height)
+
+		for turn := 0; turn <= p.turns; turn++ {
+			if !assert.NoError(t, rp.next()) {
+				return
+			}
+			assert.Equal(t, turn, rp.turn)
+			assert.Equal(t, worlds[turn], rp.world, "turn %d", turn)
+		}
+		assert.Equal(t, io.EOF, rp.next())
+
+		// 101 boards of 64x64 cells would take over 400KB even at one bit per cell.
+		info, err := os.Stat(p.recordPath)
+		check(err)
+		assert.True(t, info.Size() < 101*64*64/8/4, "%d bytes", info.Size())
+	})
+
+	t.Run("replay to a turn", func(t *testing.T) {
+		for _, turn := range []int{0, 1, 37, 100} {
+			world, err := replayTo(p.recordPath, turn)
+			assert.NoError(t, err)
+			assert.Equal(t, worlds[turn], world, "turn %d", turn)
+		}
+		_, err := replayTo(p.recordPath, 101)
+		assert.Error(t, err)
+	})
+
+	t.Run("reset and branches", func(t *testing.T) {
+		p := p
+		p.turns = 1000000000
+		p.historyTurns = 10
+		// Reset at turn 5, run on to turn 8, go back to turn 6 and branch from there, then quit at turn 9.
+		runScript(p, []scriptStep{{5, "r"}, {8, "pbbp"}, {9, "q"}}, nil)
+
+		file, err := os.Open(p.recordPath)
+		check(err)
+		defer file.Close()
+		rp, err := openRecording(file)
+		check(err)
+		var turns []int
+		for rp.next() == nil {
+			turns = append(turns, rp.turn)
+			assert.Equal(t, worlds[rp.turn], rp.world, "turn %d", rp.turn)
+		}
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 0, 1, 2, 3, 4, 5, 6, 7, 8, 6, 7, 8, 9}, turns)
+	})
+
+	t.Run("corrupt recordings", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := gzip.NewWriter(&buffer)
+		_, _ = w.Write([]byte(recordMagic + "\x02\x02K\x00\xC0\x40T\x01\x01\x09E"))
+		check(w.Close())
+		rp, err := openRecording(bytes.NewReader(buffer.Bytes()))
+		check(err)
+		assert.NoError(t, rp.next())
+		assert.Equal(t, [][]byte{{0xFF, 0xFF}, {0, 0xFF}}, rp.world)
+		assert.Error(t, rp.next(), "a flipped cell off the board")
+
+		_, err = openRecording(bytes.NewReader([]byte("not gzip")))
+		assert.Error(t, err)
+	})
+}
+
+func TestDrawWorld(t *testing.T) {
+	world := [][]byte{{0xFF, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0xFF}}
+	assert.Equal(t, "#...\n....\n...#\n", drawWorld(world, 4))
+	assert.Equal(t, "#.\n.#\n", drawWorld(world, 2))
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shipReach is how far apart cells can be and still be grouped into the same ship.
+// Spaceships such as the lightweight spaceship fall apart into pieces 2 cells apart every other turn.
+const shipReach = 2
+
+// shipShape is a phase of a known spaceship.
+type shipShape struct {
+	name   string
+	period int
+}
+
+// shipShapes maps the canonical form of every phase of the known objects that move to the object's name and period.
+var shipShapes = func() map[string]shipShape {
+	shapes := make(map[string]shipShape)
+	for _, object := range knownObjects {
+		cells := placeShape(object.shape, 0, 0)
+		start := boundingBox(cells)
+		var phases []string
+		for phase := 0; phase < object.period; phase++ {
+			phases = append(phases, canonicalShape(cells))
+			cells = stepCells(cells)
+		}
+		if boundingBox(cells) == start {
+			continue
+		}
+		for _, phase := range phases {
+			shapes[phase] = shipShape{object.name, object.period}
+		}
+	}
+	return shapes
+}()
+
+// placeShape returns the cells of a shape written as rows of '.' and 'O', moved to x, y.
+func placeShape(shape string, x, y int) []cell {
+	var cells []cell
+	for dy, row := range strings.Split(shape, "/") {
+		for dx, c := range row {
+			if c == 'O' {
+				cells = append(cells, cell{x: x + dx, y: y + dy})
+			}
+		}
+	}
+	return cells
+}
+
+// box is a rectangle of cells, from its top left corner to its bottom right corner.
+type box struct {
+	min, max cell
+}
+
+// boundingBox returns the smallest box that holds all the cells.
+func boundingBox(cells []cell) box {
+	b := box{cells[0], cells[0]}
+	for _, c := range cells {
+		if c.x < b.min.x {
+			b.min.x = c.x
+		}
+		if c.y < b.min.y {
+			b.min.y = c.y
+		}
+		if c.x > b.max.x {
+			b.max.x = c.x
+		}
+		if c.y > b.max.y {
+			b.max.y = c.y
+		}
+	}
+	return b
+}
+
+// shipTrack follows one spaceship from turn to turn.
+type shipTrack struct {
+	id     int
+	name   string
+	period int
+
+	// firstTurn is the turn the ship was first seen after.
+	// positions holds the top left of the ship's bounding box after each turn since then.
+	// They are not wrapped, so a ship that crosses an edge of the board keeps going.
+	firstTurn int
+	positions []cell
+	size      cell
+
+	// velocity is how far the ship moves in one period, once it has been seen for a whole period.
+	velocity cell
+	moving   bool
+
+	// ended says why the ship was lost, if it has been.
+	ended string
+}
+
+// speed gives the ship's speed as a fraction of the speed of light, such as c/4 for a glider.
+func (s *shipTrack) speed() string {
+	distance := abs(s.velocity.x)
+	if abs(s.velocity.y) > distance {
+		distance = abs(s.velocity.y)
+	}
+	divisor := gcd(distance, s.period)
+	if distance == divisor {
+		return fmt.Sprintf("c/%d", s.period/divisor)
+	}
+	return fmt.Sprintf("%dc/%d", distance/divisor, s.period/divisor)
+}
+
+// heading gives the compass direction the ship is moving in, with north at the top of the board.
+func (s *shipTrack) heading() string {
+	heading := ""
+	if s.velocity.y < 0 {
+		heading += "N"
+	} else if s.velocity.y > 0 {
+		heading += "S"
+	}
+	if s.velocity.x > 0 {
+		heading += "E"
+	} else if s.velocity.x < 0 {
+		heading += "W"
+	}
+	return heading
+}
+
+// shipTracker finds the spaceships on the board after each turn and matches them with those it found after the last.
+type shipTracker struct {
+	p        golParams
+	nextID   int
+	ships    []*shipTrack
+	finished []*shipTrack
+}
+
+// startShips starts tracking ships. It returns nil if tracking was not asked for.
+func startShips(p golParams) *shipTracker {
+	if !p.trackShips {
+		return nil
+	}
+	return &shipTracker{p: p, nextID: 1}
+}
+
+// turnComplete looks for the ships on the board after the given turn.
+func (t *shipTracker) turnComplete(world [][]byte, turns int) {
+	if t == nil {
+		return
+	}
+
+	width, height := t.p.imageWidth, t.p.imageHeight
+	if t.p.bounded {
+		// Nothing crosses the edges of a bounded universe.
+		width, height = 0, 0
+	}
+
+	type sighting struct {
+		shape shipShape
+		box   box
+		taken bool
+	}
+	var sightings []*sighting
+	for _, object := range connectedComponents(aliveCells(world), width, height, shipReach) {
+		if shape, ok := shipShapes[canonicalShape(object)]; ok {
+			sightings = append(sightings, &sighting{shape: shape, box: boundingBox(object)})
+		}
+	}
+
+	// Each ship should have moved no more than 2 cells since the last turn.
+	var ships []*shipTrack
+	for _, ship := range t.ships {
+		last := ship.positions[len(ship.positions)-1]
+		var found *sighting
+		var step cell
+		for _, s := range sightings {
+			if s.taken || s.shape.name != ship.name {
+				continue
+			}
+			d := cell{x: t.wrapDistance(s.box.min.x-last.x, t.p.imageWidth), y: t.wrapDistance(s.box.min.y-last.y, t.p.imageHeight)}
+			if abs(d.x) <= 2 && abs(d.y) <= 2 && (found == nil || abs(d.x)+abs(d.y) < abs(step.x)+abs(step.y)) {
+				found, step = s, d
+			}
+		}
+
+		if found == nil {
+			t.end(ship, turns)
+			continue
+		}
+		found.taken = true
+		ship.positions = append(ship.positions, cell{x: last.x + step.x, y: last.y + step.y})
+		ship.size = cell{x: found.box.max.x - found.box.min.x + 1, y: found.box.max.y - found.box.min.y + 1}
+
+		// The shape is the same a whole period later, so the ship has moved exactly its velocity.
+		if n := len(ship.positions); n > ship.period {
+			from, to := ship.positions[n-1-ship.period], ship.positions[n-1]
+			velocity := cell{x: to.x - from.x, y: to.y - from.y}
+			if !ship.moving || velocity != ship.velocity {
+				ship.velocity, ship.moving = velocity, true
+				fmt.Printf("Turn %d: %s %d heading %s at %s\n", turns, ship.name, ship.id, ship.heading(), ship.speed())
+			}
+		}
+		ships = append(ships, ship)
+	}
+
+	for _, s := range sightings {
+		if s.taken {
+			continue
+		}
+		ship := &shipTrack{
+			id:        t.nextID,
+			name:      s.shape.name,
+			period:    s.shape.period,
+			firstTurn: turns,
+			positions: []cell{s.box.min},
+			size:      cell{x: s.box.max.x - s.box.min.x + 1, y: s.box.max.y - s.box.min.y + 1},
+		}
+		t.nextID++
+		fmt.Printf("Turn %d: %s %d seen at (%d, %d)\n", turns, ship.name, ship.id, s.box.min.x, s.box.min.y)
+		ships = append(ships, ship)
+	}
+	t.ships = ships
+}
+
+// end stops following a ship that was not found after the given turn.
+// In a bounded universe a ship that was at the edge has left it, otherwise it must have hit something.
+func (t *shipTracker) end(ship *shipTrack, turns int) {
+	last := ship.positions[len(ship.positions)-1]
+	if t.p.bounded && (last.x <= 1 || last.y <= 1 || last.x+ship.size.x >= t.p.imageWidth-1 || last.y+ship.size.y >= t.p.imageHeight-1) {
+		ship.ended = "left the universe"
+	} else {
+		ship.ended = "collided"
+	}
+	fmt.Printf("Turn %d: %s %d %s at (%d, %d)\n", turns, ship.name, ship.id, ship.ended, t.wrap(last.x, t.p.imageWidth), t.wrap(last.y, t.p.imageHeight))
+	t.finished = append(t.finished, ship)
+}
+
+// wrapDistance returns the shortest distance from one coordinate to another, which on a torus may be across an edge.
+func (t *shipTracker) wrapDistance(d, size int) int {
+	if t.p.bounded {
+		return d
+	}
+	d = t.wrap(d, size)
+	if d > size/2 {
+		d -= size
+	}
+	return d
+}
+
+// wrap brings a coordinate back onto the board.
+func (t *shipTracker) wrap(v, size int) int {
+	return (v%size + size) % size
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
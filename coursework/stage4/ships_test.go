@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// trackTurns runs the reference engine from the world for n turns and tracks the ships after each one.
+func trackTurns(p golParams, world [][]byte, n int) *shipTracker {
+	tracker := startShips(p)
+	tracker.turnComplete(world, 0)
+	for turn := 1; turn <= n; turn++ {
+		world = referenceStep(p, world)
+		tracker.turnComplete(world, turn)
+	}
+	return tracker
+}
+
+func TestShips(t *testing.T) {
+	t.Run("glider on a torus", func(t *testing.T) {
+		p := golParams{imageWidth: 16, imageHeight: 16, trackShips: true}
+		tracker := trackTurns(p, referenceWorlds(p, 0)[0], 100)
+
+		assert.Empty(t, tracker.finished)
+		if assert.Len(t, tracker.ships, 1) {
+			ship := tracker.ships[0]
+			assert.Equal(t, "glider", ship.name)
+			assert.Equal(t, "c/4", ship.speed())
+			assert.Len(t, ship.heading(), 2)
+			// It has crossed the edges of the board but is still the same glider, 25 cells from where it started.
+			assert.Equal(t, 1, ship.id)
+			start, end := ship.positions[0], ship.positions[len(ship.positions)-1]
+			assert.Equal(t, 25, abs(end.x-start.x))
+			assert.Equal(t, 25, abs(end.y-start.y))
+		}
+	})
+
+	t.Run("spaceships", func(t *testing.T) {
+		p := golParams{imageWidth: 64, imageHeight: 64, trackShips: true}
+		var alive []cell
+		alive = append(alive, placeShape(".O..O/O..../O...O/OOOO.", 30, 5)...)
+		alive = append(alive, placeShape("..O.../O...O./.....O/O....O/.OOOOO", 10, 25)...)
+		alive = append(alive, placeShape("..OO.../O....O./......O/O.....O/.OOOOOO", 10, 45)...)
+		tracker := trackTurns(p, worldOf(p, alive), 40)
+
+		assert.Empty(t, tracker.finished)
+		var ships []string
+		for _, ship := range tracker.ships {
+			ships = append(ships, ship.name+" "+ship.heading()+" "+ship.speed())
+		}
+		assert.ElementsMatch(t, []string{
+			"lightweight spaceship W c/2",
+			"middleweight spaceship E c/2",
+			"heavyweight spaceship E c/2",
+		}, ships)
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		p := golParams{imageWidth: 64, imageHeight: 64, trackShips: true}
+		var alive []cell
+		alive = append(alive, placeShape(".O./..O/OOO", 10, 10)...)
+		alive = append(alive, placeShape("OOO/O../.O.", 30, 30)...)
+		tracker := trackTurns(p, worldOf(p, alive), 80)
+
+		if assert.Len(t, tracker.finished, 2) {
+			for _, ship := range tracker.finished {
+				assert.Equal(t, "collided", ship.ended)
+			}
+		}
+	})
+
+	t.Run("leaving a bounded universe", func(t *testing.T) {
+		p := golParams{imageWidth: 16, imageHeight: 16, bounded: true, trackShips: true}
+		tracker := trackTurns(p, worldOf(p, placeShape(".O./..O/OOO", 6, 6)), 40)
+
+		assert.Empty(t, tracker.ships)
+		if assert.Len(t, tracker.finished, 1) {
+			assert.Equal(t, "SE", tracker.finished[0].heading())
+			assert.Equal(t, "left the universe", tracker.finished[0].ended)
+		}
+	})
+}
+
+func TestBounded(t *testing.T) {
+	for _, size := range []int{16, 64} {
+		p := golParams{
+			turns:       100,
+			threads:     4,
+			imageWidth:  size,
+			imageHeight: size,
+			bounded:     true,
+		}
+		worlds := referenceWorlds(p, p.turns)
+		cleanOutput(t)
+
+		alive := gameOfLife(p, nil, nil)
+		assertAlive(t, p, aliveCells(worlds[p.turns]), alive, "%dx%d", size, size)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Formats the population history can be saved in.
+const (
+	statsCsv   = "csv"
+	statsJsonl = "jsonl"
+)
+
+// statsHeader is the first line of a csv history, naming the fields of statsRow in order.
+const statsHeader = "turn,population,births,deaths,min_x,min_y,max_x,max_y"
+
+// statsRow is the population of the world after a turn.
+// Births and deaths are counted since the previous row, so each row's population is the last one's plus births minus deaths.
+// The bounding box is the smallest rectangle holding every alive cell, or all -1 if there are none.
+type statsRow struct {
+	Turn       int `json:"turn"`
+	Population int `json:"population"`
+	Births     int `json:"births"`
+	Deaths     int `json:"deaths"`
+	MinX       int `json:"min_x"`
+	MinY       int `json:"min_y"`
+	MaxX       int `json:"max_x"`
+	MaxY       int `json:"max_y"`
+}
+
+// statsRecorder writes a row of statistics to out/<name>_stats.<format> every p.statsEvery turns.
+type statsRecorder struct {
+	p      golParams
+	path   string
+	file   *os.File
+	w      *bufio.Writer
+	births int
+	deaths int
+}
+
+// startStats creates the history file for the run. It returns nil if no history was asked for.
+func startStats(p golParams) *statsRecorder {
+	if p.statsEvery <= 0 {
+		return nil
+	}
+	format := p.statsFormat
+	if format == "" {
+		format = statsCsv
+	}
+
+	_ = os.Mkdir("out", os.ModePerm)
+	s := &statsRecorder{p: p}
+	s.path = "out/" + strconv.Itoa(p.imageWidth) + "x" + strconv.Itoa(p.imageHeight) + "_stats." + format
+	var ioError error
+	s.file, ioError = os.Create(s.path)
+	check(ioError)
+	s.w = bufio.NewWriter(s.file)
+	if format == statsCsv {
+		_, _ = s.w.WriteString(statsHeader + "\n")
+	}
+	return s
+}
+
+// turnComplete counts the births and deaths of a turn, and writes a row if one is due.
+// After a reset the rows start again from turn 0, once reset has been called.
+func (s *statsRecorder) turnComplete(world [][]byte, turns, alive, births, deaths int) {
+	if s == nil {
+		return
+	}
+	s.births += births
+	s.deaths += deaths
+	if turns%s.p.statsEvery != 0 {
+		return
+	}
+
+	row := statsRow{Turn: turns, Population: alive, Births: s.births, Deaths: s.deaths, MinX: -1, MinY: -1, MaxX: -1, MaxY: -1}
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 0 {
+				continue
+			}
+			if row.MinY == -1 {
+				row.MinY = y
+			}
+			row.MaxY = y
+			if row.MinX == -1 || x < row.MinX {
+				row.MinX = x
+			}
+			if x > row.MaxX {
+				row.MaxX = x
+			}
+		}
+	}
+	s.births, s.deaths = 0, 0
+
+	if strings.HasSuffix(s.path, "."+statsJsonl) {
+		line, err := json.Marshal(row)
+		check(err)
+		_, _ = s.w.Write(append(line, '\n'))
+	} else {
+		fields := []int{row.Turn, row.Population, row.Births, row.Deaths, row.MinX, row.MinY, row.MaxX, row.MaxY}
+		for i, field := range fields {
+			if i > 0 {
+				_ = s.w.WriteByte(',')
+			}
+			_, _ = s.w.WriteString(strconv.Itoa(field))
+		}
+		_ = s.w.WriteByte('\n')
+	}
+}
+
+// reset forgets the births and deaths counted since the last row, which are from before the world was reset.
+func (s *statsRecorder) reset() {
+	if s == nil {
+		return
+	}
+	s.births, s.deaths = 0, 0
+}
+
+// finish writes any buffered rows and closes the file.
+func (s *statsRecorder) finish() {
+	if s == nil {
+		return
+	}
+	check(s.w.Flush())
+	check(s.file.Close())
+	fmt.Println("File", s.path, "output done!")
+}
+
+// readStats reads a history written as csv or as JSON lines.
+func readStats(path string) ([]statsRow, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []statsRow
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == statsHeader {
+			continue
+		}
+
+		var row statsRow
+		if strings.HasPrefix(line, "{") {
+			if err := json.Unmarshal([]byte(line), &row); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n+1, err)
+			}
+		} else {
+			fields := strings.Split(line, ",")
+			if len(fields) != 8 {
+				return nil, fmt.Errorf("%s:%d: expected 8 fields", path, n+1)
+			}
+			values := make([]int, len(fields))
+			for i := range fields {
+				if values[i], err = strconv.Atoi(fields[i]); err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", path, n+1, err)
+				}
+			}
+			row = statsRow{values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7]}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New(path + ": no rows")
+	}
+	return rows, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// expectedStats works out the rows that should be saved every `every` turns from the reference worlds.
+func expectedStats(worlds [][][]byte, every int) []statsRow {
+	var rows []statsRow
+	births, deaths := 0, 0
+	for turn, world := range worlds {
+		if turn > 0 {
+			for y := range world {
+				for x := range world[y] {
+					if world[y][x] != worlds[turn-1][y][x] {
+						if world[y][x] != 0 {
+							births++
+						} else {
+							deaths++
+						}
+					}
+				}
+			}
+		}
+		if turn%every != 0 {
+			continue
+		}
+
+		row := statsRow{Turn: turn, Births: births, Deaths: deaths, MinX: -1, MinY: -1, MaxX: -1, MaxY: -1}
+		for _, c := range aliveCells(world) {
+			row.Population++
+			if row.MinX == -1 || c.x < row.MinX {
+				row.MinX = c.x
+			}
+			if row.MinY == -1 || c.y < row.MinY {
+				row.MinY = c.y
+			}
+			if c.x > row.MaxX {
+				row.MaxX = c.x
+			}
+			if c.y > row.MaxY {
+				row.MaxY = c.y
+			}
+		}
+		rows = append(rows, row)
+		births, deaths = 0, 0
+	}
+	return rows
+}
+
+func TestStats(t *testing.T) {
+	for _, format := range []string{statsCsv, statsJsonl} {
+		t.Run(format, func(t *testing.T) {
+			p := golParams{
+				turns:       50,
+				threads:     4,
+				imageWidth:  64,
+				imageHeight: 64,
+				statsEvery:  5,
+				statsFormat: format,
+			}
+			worlds := referenceWorlds(p, p.turns)
+			cleanOutput(t)
+
+			gameOfLife(p, nil, nil)
+
+			rows, err := readStats("out/64x64_stats." + format)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedStats(worlds, p.statsEvery), rows)
+		})
+	}
+}
+
+// TestStatsReset presses 'r' between rows, so the rows start again from turn 0
+// without the births and deaths of the turns before the reset.
+func TestStatsReset(t *testing.T) {
+	p := golParams{
+		turns:       50,
+		threads:     4,
+		imageWidth:  64,
+		imageHeight: 64,
+		statsEvery:  5,
+	}
+	worlds := referenceWorlds(p, 12)
+	cleanOutput(t)
+
+	captureStdout(func() {
+		runScript(p, []scriptStep{{7, "r"}, {12, "q"}}, nil)
+	})
+
+	rows, err := readStats("out/64x64_stats." + statsCsv)
+	assert.NoError(t, err)
+	expected := append(expectedStats(worlds[:8], p.statsEvery), expectedStats(worlds, p.statsEvery)...)
+	assert.Equal(t, expected, rows)
+}
+
+func TestPlotPopulation(t *testing.T) {
+	var rows []statsRow
+	for turn := 0; turn <= 100; turn++ {
+		rows = append(rows, statsRow{Turn: turn, Population: 1000 + 10*turn})
+	}
+
+	chart := plotPopulation(rows, 40, 10)
+	lines := strings.Split(strings.TrimSuffix(chart, "\n"), "\n")
+	assert.Len(t, lines, 12)
+	assert.True(t, strings.HasPrefix(lines[0], "2000 | "), lines[0])
+	assert.True(t, strings.HasSuffix(lines[0], "*"), lines[0])
+	assert.True(t, strings.HasPrefix(lines[9], "1000 |*"), lines[9])
+	assert.Equal(t, "     +"+strings.Repeat("-", 40), lines[10])
+	assert.Equal(t, "      0"+strings.Repeat(" ", 36)+"100", lines[11])
+
+	// A rising population never goes down the chart from left to right.
+	last := len(lines)
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 10; y++ {
+			if len(lines[y]) > 6+x && lines[y][6+x] == '*' {
+				assert.True(t, y <= last, "column %d", x)
+				last = y
+			}
+		}
+	}
+
+	// Fewer rows than columns are plotted one per column.
+	short := plotPopulation(rows[:3], 40, 5)
+	assert.Equal(t, 3, strings.Count(short, "*"))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// series is one line on a chart, through points given as {x, y}.
+type series struct {
+	name   string
+	points [][2]float64
+	dashed bool
+}
+
+// chart is a line chart with a legend, drawn as SVG.
+type chart struct {
+	title, xLabel, yLabel string
+	series                []series
+}
+
+// The size of a chart, and the space around the plot for the labels and the legend.
+const (
+	chartWidth   = 720
+	chartHeight  = 420
+	marginLeft   = 70
+	marginRight  = 160
+	marginTop    = 40
+	marginBottom = 50
+)
+
+// colours are picked in turn for the lines that are not dashed.
+var colours = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#17becf"}
+
+// niceStep returns a step of 1, 2 or 5 times a power of ten that splits the range into about the given number of ticks.
+func niceStep(span float64, ticks int) float64 {
+	if span <= 0 {
+		return 1
+	}
+	raw := span / float64(ticks)
+	power := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if m*power >= raw {
+			return m * power
+		}
+	}
+	return 10 * power
+}
+
+// formatTick rounds off what adding up steps like 0.1 leaves behind.
+func formatTick(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+// writeSVG draws the chart. The y axis starts at 0, and each distinct x in the points gets a tick.
+func (c chart) writeSVG(w io.Writer) error {
+	var b strings.Builder
+	plotWidth := float64(chartWidth - marginLeft - marginRight)
+	plotHeight := float64(chartHeight - marginTop - marginBottom)
+
+	xs := make(map[float64]bool)
+	minX, maxX, maxY := math.Inf(1), math.Inf(-1), 0.0
+	for _, s := range c.series {
+		for _, p := range s.points {
+			xs[p[0]] = true
+			minX, maxX, maxY = math.Min(minX, p[0]), math.Max(maxX, p[0]), math.Max(maxY, p[1])
+		}
+	}
+	if len(xs) == 0 {
+		minX, maxX = 0, 1
+	}
+	if minX == maxX {
+		minX, maxX = minX-1, maxX+1
+	}
+	step := niceStep(maxY, 5)
+	maxY = math.Max(step, math.Ceil(maxY/step)*step)
+	x := func(v float64) float64 { return marginLeft + (v-minX)/(maxX-minX)*plotWidth }
+	y := func(v float64) float64 { return marginTop + plotHeight - v/maxY*plotHeight }
+
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-size="16">%s</text>`+"\n",
+		marginLeft+int(plotWidth)/2, marginTop/2+6, html.EscapeString(c.title))
+
+	// Grid lines and ticks.
+	for i := 0; float64(i)*step <= maxY+step/2; i++ {
+		v := float64(i) * step
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+"\n", marginLeft, y(v), x(maxX), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", marginLeft-6, y(v)+4, formatTick(v))
+	}
+	var ticks []float64
+	for v := range xs {
+		ticks = append(ticks, v)
+	}
+	sort.Float64s(ticks)
+	for _, v := range ticks {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", x(v), y(0)+18, formatTick(v))
+	}
+
+	// Axes and their labels.
+	fmt.Fprintf(&b, `<path d="M%d %d V%.1f H%.1f" fill="none" stroke="black"/>`+"\n", marginLeft, marginTop, y(0), x(maxX))
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n",
+		marginLeft+plotWidth/2, chartHeight-10, html.EscapeString(c.xLabel))
+	fmt.Fprintf(&b, `<text transform="translate(18 %.1f) rotate(-90)" text-anchor="middle">%s</text>`+"\n",
+		marginTop+plotHeight/2, html.EscapeString(c.yLabel))
+
+	// The lines, each with an entry in the legend.
+	colour := 0
+	for i, s := range c.series {
+		stroke, style := "#888", ` stroke-dasharray="6 4"`
+		if !s.dashed {
+			stroke, style = colours[colour%len(colours)], ""
+			colour++
+		}
+		if len(s.points) > 0 {
+			var d []string
+			for j, p := range s.points {
+				command := "L"
+				if j == 0 {
+					command = "M"
+				}
+				d = append(d, fmt.Sprintf("%s%.1f %.1f", command, x(p[0]), y(p[1])))
+			}
+			fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s" stroke-width="2"%s/>`+"\n", strings.Join(d, " "), stroke, style)
+			if !s.dashed {
+				for _, p := range s.points {
+					fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`+"\n", x(p[0]), y(p[1]), stroke)
+				}
+			}
+		}
+		legendY := marginTop + 10 + 20*i
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="2"%s/>`+"\n",
+			chartWidth-marginRight+15, legendY, chartWidth-marginRight+40, legendY, stroke, style)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", chartWidth-marginRight+46, legendY+4, html.EscapeString(s.name))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config is one run of the sweep: an engine, the width and height of its world, and its number of threads.
+type config struct {
+	engine  string
+	size    int
+	threads int
+}
+
+func (c config) String() string {
+	return fmt.Sprintf("%s %dx%d on %d threads", c.engine, c.size, c.size, c.threads)
+}
+
+// measurement is what one run of a config's benchmark took, per turn.
+type measurement struct {
+	nsPerTurn     float64
+	bytesPerTurn  float64
+	allocsPerTurn float64
+
+	// cpu is the percentage of a CPU the run got, as (user + system time) / elapsed time.
+	cpu float64
+}
+
+// point is the mean of every run of a config, with its speed-up and parallel efficiency
+// over the same engine and size on one thread. Both are 0 if that was not measured.
+type point struct {
+	config
+	runs       int
+	mean       measurement
+	speedUp    float64
+	efficiency float64
+}
+
+func check(e error) {
+	if e != nil {
+		panic(e)
+	}
+}
+
+// errSkipped is returned for configs the engine cannot run, such as thread counts that do not divide the height.
+var errSkipped = errors.New("skipped")
+
+// parseSweep reads the result of BenchmarkSweep from the verbose output of a test binary.
+// Allocations are reported per run of the benchmark, so they are divided by the turns in each run.
+func parseSweep(output []byte, turns int) (measurement, error) {
+	var m measurement
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "---" && fields[1] == "SKIP:" {
+			return m, errSkipped
+		}
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "BenchmarkSweep") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return m, fmt.Errorf("bad result %q in %q", fields[i], scanner.Text())
+			}
+			switch fields[i+1] {
+			case "ns/turn":
+				m.nsPerTurn = value
+				found = true
+			case "B/op":
+				m.bytesPerTurn = value / float64(turns)
+			case "allocs/op":
+				m.allocsPerTurn = value / float64(turns)
+			}
+		}
+	}
+	if !found {
+		return m, errors.New("no ns/turn in the benchmark output")
+	}
+	return m, scanner.Err()
+}
+
+// runSweep runs the benchmark of one config with a test binary built from the engine's directory.
+func runSweep(binary, dir string, c config) (measurement, error) {
+	cmd := exec.Command(binary, "-test.v", "-test.run", "XXX", "-test.bench", "^BenchmarkSweep$", "-test.benchmem",
+		"-test.benchtime", *benchtime, "-sweep.size", strconv.Itoa(c.size), "-sweep.threads", strconv.Itoa(c.threads),
+		"-sweep.turns", strconv.Itoa(*turns))
+	cmd.Dir = dir
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	elapsed := time.Since(start)
+	if err != nil {
+		return measurement{}, fmt.Errorf("%v: %v\n%s", c, err, output)
+	}
+	m, err := parseSweep(output, *turns)
+	if err != nil {
+		return m, err
+	}
+	cpuTime := cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+	m.cpu = 100 * float64(cpuTime) / float64(elapsed)
+	return m, nil
+}
+
+// summarise averages the runs of each config, and works out the speed-ups over one thread.
+func summarise(configs []config, runs map[config][]measurement) []point {
+	var points []point
+	oneThread := make(map[config]float64)
+	for _, c := range configs {
+		ms := runs[c]
+		if len(ms) == 0 {
+			continue
+		}
+		p := point{config: c, runs: len(ms)}
+		for _, m := range ms {
+			p.mean.nsPerTurn += m.nsPerTurn / float64(len(ms))
+			p.mean.bytesPerTurn += m.bytesPerTurn / float64(len(ms))
+			p.mean.allocsPerTurn += m.allocsPerTurn / float64(len(ms))
+			p.mean.cpu += m.cpu / float64(len(ms))
+		}
+		if c.threads == 1 {
+			oneThread[config{engine: c.engine, size: c.size}] = p.mean.nsPerTurn
+		}
+		points = append(points, p)
+	}
+	for i, p := range points {
+		if base, ok := oneThread[config{engine: p.engine, size: p.size}]; ok && p.mean.nsPerTurn > 0 {
+			points[i].speedUp = base / p.mean.nsPerTurn
+			points[i].efficiency = points[i].speedUp / float64(p.threads)
+		}
+	}
+	return points
+}
+
+// writeCSV writes a row for each point, leaving the speed-up and efficiency empty where there was no run on one thread.
+func writeCSV(w io.Writer, points []point) error {
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"engine", "size", "threads", "runs", "ns_per_turn", "cpu_percent", "bytes_per_turn",
+		"allocs_per_turn", "speed_up", "efficiency"})
+	number := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	for _, p := range points {
+		speedUp, efficiency := "", ""
+		if p.speedUp > 0 {
+			speedUp, efficiency = number(p.speedUp), number(p.efficiency)
+		}
+		_ = out.Write([]string{p.engine, strconv.Itoa(p.size), strconv.Itoa(p.threads), strconv.Itoa(p.runs),
+			number(p.mean.nsPerTurn), number(p.mean.cpu), number(p.mean.bytesPerTurn), number(p.mean.allocsPerTurn),
+			speedUp, efficiency})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// charts draws the time per turn, the speed-up and the efficiency against the number of threads,
+// with a line for each engine and a chart for each size. They are named after what they show and the size.
+func charts(points []point) map[string]chart {
+	charts := make(map[string]chart)
+	for _, size := range sizesOf(points) {
+		name := fmt.Sprintf("%dx%d", size, size)
+		times := chart{title: "Time per turn, " + name, xLabel: "Threads", yLabel: "µs per turn"}
+		speedUps := chart{title: "Speed-up over one thread, " + name, xLabel: "Threads", yLabel: "Speed-up"}
+		efficiencies := chart{title: "Parallel efficiency, " + name, xLabel: "Threads", yLabel: "Efficiency"}
+
+		var maxThreads float64
+		for _, engine := range enginesOf(points) {
+			var t, s, e series
+			t.name, s.name, e.name = engine, engine, engine
+			for _, p := range points {
+				if p.engine != engine || p.size != size {
+					continue
+				}
+				x := float64(p.threads)
+				if x > maxThreads {
+					maxThreads = x
+				}
+				t.points = append(t.points, [2]float64{x, p.mean.nsPerTurn / 1000})
+				if p.speedUp > 0 {
+					s.points = append(s.points, [2]float64{x, p.speedUp})
+					e.points = append(e.points, [2]float64{x, p.efficiency})
+				}
+			}
+			times.series = append(times.series, t)
+			speedUps.series = append(speedUps.series, s)
+			efficiencies.series = append(efficiencies.series, e)
+		}
+		// Perfect scaling, for comparison.
+		speedUps.series = append(speedUps.series, series{name: "ideal", points: [][2]float64{{1, 1}, {maxThreads, maxThreads}}, dashed: true})
+		efficiencies.series = append(efficiencies.series, series{name: "ideal", points: [][2]float64{{1, 1}, {maxThreads, 1}}, dashed: true})
+
+		charts["time_"+name+".svg"] = times
+		charts["speedup_"+name+".svg"] = speedUps
+		charts["efficiency_"+name+".svg"] = efficiencies
+	}
+	return charts
+}
+
+// sizesOf lists the sizes in the points, smallest first.
+func sizesOf(points []point) []int {
+	seen := make(map[int]bool)
+	var sizes []int
+	for _, p := range points {
+		if !seen[p.size] {
+			seen[p.size] = true
+			sizes = append(sizes, p.size)
+		}
+	}
+	sort.Ints(sizes)
+	return sizes
+}
+
+// enginesOf lists the engines in the points, in the order they were run.
+func enginesOf(points []point) []string {
+	seen := make(map[string]bool)
+	var engines []string
+	for _, p := range points {
+		if !seen[p.engine] {
+			seen[p.engine] = true
+			engines = append(engines, p.engine)
+		}
+	}
+	return engines
+}
+
+// parseInts reads a comma separated list of positive numbers.
+func parseInts(list string) ([]int, error) {
+	var ints []int
+	for _, field := range strings.Split(list, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || i < 1 {
+			return nil, fmt.Errorf("bad number %q in %q", field, list)
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
+
+// Flags for the sweep.
+var (
+	engines   = flag.String("engines", "../Stage 1A,../Stage 1B,../stage2,../stage3,.,../stage5", "Specify the directories of the engines to sweep, separated by commas.")
+	sizes     = flag.String("sizes", "64,128,256,512", "Specify the widths and heights of the worlds to sweep, separated by commas.")
+	threads   = flag.String("threads", "1,2,4,8", "Specify the numbers of threads to sweep, separated by commas. One thread is always included, for the speed-ups.")
+	turns     = flag.Int("turns", 100, "Specify the number of turns in each run of a benchmark.")
+	runs      = flag.Int("runs", 3, "Specify how many times to run each benchmark.")
+	benchtime = flag.String("benchtime", "1x", "Specify the -benchtime for each run of a benchmark.")
+	out       = flag.String("out", "sweep-results", "Specify the directory to write sweep.csv and the charts to.")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: sweep [-engines E] [-sizes S] [-threads T] [-turns N] [-runs R] [-benchtime B] [-out O]")
+	fmt.Fprintln(os.Stderr, "It runs BenchmarkSweep of every engine on every size and number of threads, each in a process of its own.")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	sizeList, err := parseInts(*sizes)
+	threadList, threadErr := parseInts(*threads)
+	if flag.NArg() != 0 || err != nil || threadErr != nil || *turns < 1 || *runs < 1 {
+		usage()
+		os.Exit(2)
+	}
+	sort.Ints(threadList)
+	if threadList[0] != 1 {
+		threadList = append([]int{1}, threadList...)
+	}
+
+	bin, err := ioutil.TempDir("", "sweep")
+	check(err)
+	defer os.RemoveAll(bin)
+
+	var configs []config
+	binaries := make(map[string]string)
+	dirs := make(map[string]string)
+	for _, dir := range strings.Split(*engines, ",") {
+		dir, err := filepath.Abs(strings.TrimSpace(dir))
+		check(err)
+		engine := filepath.Base(dir)
+		fmt.Fprintln(os.Stderr, "Building", engine)
+		binaries[engine] = filepath.Join(bin, strings.ReplaceAll(engine, " ", "_")+".test")
+		dirs[engine] = dir
+		build := exec.Command("go", "test", "-c", "-o", binaries[engine])
+		build.Dir = dir
+		build.Stdout, build.Stderr = os.Stderr, os.Stderr
+		check(build.Run())
+		for _, size := range sizeList {
+			for _, t := range threadList {
+				configs = append(configs, config{engine: engine, size: size, threads: t})
+			}
+		}
+	}
+
+	// Every config runs once before any runs again, so that anything else slowing the machine down affects them alike.
+	measurements := make(map[config][]measurement)
+	skipped := make(map[config]bool)
+	for i := 1; i <= *runs; i++ {
+		for _, c := range configs {
+			if skipped[c] {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%v, run %d of %d\n", c, i, *runs)
+			m, err := runSweep(binaries[c.engine], dirs[c.engine], c)
+			if err == errSkipped {
+				fmt.Fprintf(os.Stderr, "%s cannot run %dx%d on %d threads\n", c.engine, c.size, c.size, c.threads)
+				skipped[c] = true
+				continue
+			}
+			check(err)
+			measurements[c] = append(measurements[c], m)
+		}
+	}
+	points := summarise(configs, measurements)
+
+	check(os.MkdirAll(*out, os.ModePerm))
+	file, err := os.Create(filepath.Join(*out, "sweep.csv"))
+	check(err)
+	check(writeCSV(file, points))
+	check(file.Close())
+	for name, c := range charts(points) {
+		file, err := os.Create(filepath.Join(*out, name))
+		check(err)
+		check(c.writeSVG(file))
+		check(file.Close())
+	}
+	fmt.Fprintln(os.Stderr, "Written to", *out)
+}
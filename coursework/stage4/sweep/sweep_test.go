@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSweep(t *testing.T) {
+	m, err := parseSweep([]byte("goos: linux\nBenchmarkSweep\nBenchmarkSweep-8 \t 3\t 72030199 ns/op\t 720302 ns/turn\t 14380082 B/op\t 206214 allocs/op\nPASS\n"), 100)
+	assert.NoError(t, err)
+	assert.Equal(t, measurement{nsPerTurn: 720302, bytesPerTurn: 143800.82, allocsPerTurn: 2062.14}, m)
+
+	_, err = parseSweep([]byte("BenchmarkSweep\n    sweep.go:42: The engine cannot run it\n--- SKIP: BenchmarkSweep\nPASS\n"), 100)
+	assert.Equal(t, errSkipped, err)
+
+	_, err = parseSweep([]byte("BenchmarkSweep \t 3\t 72030199 ns/op\nPASS\n"), 100)
+	assert.Error(t, err, "without -benchmem and ns/turn there is nothing to go on")
+}
+
+func TestSummarise(t *testing.T) {
+	one := config{engine: "stage4", size: 64, threads: 1}
+	four := config{engine: "stage4", size: 64, threads: 4}
+	alone := config{engine: "Stage 1B", size: 64, threads: 4}
+	skipped := config{engine: "Stage 1B", size: 64, threads: 3}
+	points := summarise([]config{one, four, alone, skipped}, map[config][]measurement{
+		one:   {{nsPerTurn: 1000, cpu: 100}, {nsPerTurn: 1200, cpu: 100}},
+		four:  {{nsPerTurn: 400, cpu: 300}},
+		alone: {{nsPerTurn: 100}},
+	})
+
+	if assert.Len(t, points, 3) {
+		assert.Equal(t, 2, points[0].runs)
+		assert.Equal(t, 1100.0, points[0].mean.nsPerTurn)
+		assert.Equal(t, 1.0, points[0].speedUp)
+		assert.Equal(t, 2.75, points[1].speedUp)
+		assert.InDelta(t, 0.6875, points[1].efficiency, 1e-9)
+		assert.Equal(t, 0.0, points[2].speedUp, "with no run on one thread there is no speed-up")
+	}
+
+	var buffer bytes.Buffer
+	assert.NoError(t, writeCSV(&buffer, points))
+	assert.Equal(t, "engine,size,threads,runs,ns_per_turn,cpu_percent,bytes_per_turn,allocs_per_turn,speed_up,efficiency\n"+
+		"stage4,64,1,2,1100,100,0,0,1,1\n"+
+		"stage4,64,4,1,400,300,0,0,2.75,0.6875\n"+
+		"Stage 1B,64,4,1,100,0,0,0,,\n", buffer.String())
+}
+
+func TestCharts(t *testing.T) {
+	var points []point
+	for _, engine := range []string{"stage4", "<stage&5>"} {
+		for _, size := range []int{128, 64} {
+			for _, threads := range []int{1, 2, 4} {
+				points = append(points, point{
+					config:     config{engine: engine, size: size, threads: threads},
+					mean:       measurement{nsPerTurn: 1000000 / float64(threads)},
+					speedUp:    float64(threads),
+					efficiency: 1,
+				})
+			}
+		}
+	}
+	charts := charts(points)
+	var names []string
+	for name := range charts {
+		names = append(names, name)
+	}
+	assert.ElementsMatch(t, []string{"time_64x64.svg", "speedup_64x64.svg", "efficiency_64x64.svg",
+		"time_128x128.svg", "speedup_128x128.svg", "efficiency_128x128.svg"}, names)
+
+	speedUps := charts["speedup_64x64.svg"]
+	if assert.Len(t, speedUps.series, 3) {
+		assert.Equal(t, [][2]float64{{1, 1}, {2, 2}, {4, 4}}, speedUps.series[0].points)
+		assert.True(t, speedUps.series[2].dashed, "the ideal line is dashed")
+	}
+	assert.Equal(t, [2]float64{4, 250}, charts["time_64x64.svg"].series[1].points[2], "times are in µs")
+
+	for name, c := range charts {
+		var buffer bytes.Buffer
+		assert.NoError(t, c.writeSVG(&buffer))
+		decoder := xml.NewDecoder(&buffer)
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err, name) {
+				break
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	assert.NoError(t, charts["time_128x128.svg"].writeSVG(&buffer))
+	svg := buffer.String()
+	assert.Contains(t, svg, "&lt;stage&amp;5&gt;")
+	assert.Contains(t, svg, ">1000</text>", "the top of the y axis")
+	assert.Equal(t, 2*3, strings.Count(svg, "<circle"))
+}
+
+func TestNiceStep(t *testing.T) {
+	assert.Equal(t, 1.0, niceStep(4, 5))
+	assert.Equal(t, 0.2, niceStep(1, 5))
+	assert.Equal(t, 500.0, niceStep(2100, 5))
+	assert.Equal(t, "0.3", formatTick(3*0.1))
+}
+
+func TestParseInts(t *testing.T) {
+	ints, err := parseInts("1, 2,4")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 4}, ints)
+	_, err = parseInts("1,0")
+	assert.Error(t, err)
+	_, err = parseInts("1,,2")
+	assert.Error(t, err)
+}
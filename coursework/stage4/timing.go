@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// workerTiming is how a worker spent a turn, sent to the distributor with its report.
+type workerTiming struct {
+	// start is when it started calculating the turn.
+	start time.Time
+
+	// wait is the time on the chans with the distributor before start: sending its last report and
+	// waiting to be sent this turn. It includes anything the distributor did in between, such as pausing.
+	wait time.Duration
+
+	// compute is the time calculating the turn, and halo the time exchanging halos with its neighbours after it.
+	compute time.Duration
+	halo    time.Duration
+
+	// rows is how many rows of the world the worker calculated.
+	rows int
+}
+
+// imbalance describes how unevenly the work of the turns was shared between the workers.
+type imbalance struct {
+	turns int
+
+	// mean is the mean over the turns of how much longer the slowest worker computed than the average worker,
+	// as a fraction of the average. worst is the biggest of those, in worstTurn.
+	mean      float64
+	worst     float64
+	worstTurn int
+
+	// idle is the share of the workers' compute time that was spent waiting for the slowest worker of each turn instead.
+	idle float64
+}
+
+// timingRecorder adds up the timings of every worker over the run, and writes them to a timeline if one was asked for.
+type timingRecorder struct {
+	p golParams
+
+	// The totals for each worker. rows is how many it has now, and rowTurns the rows it calculated over all the turns,
+	// which differ when rows are moved between the workers.
+	compute, halo, wait []time.Duration
+	rows, rowTurns      []int
+
+	imbalance                imbalance
+	imbalanceSum             float64
+	slowestSum, totalCompute time.Duration
+
+	// timeline is the Chrome trace event file, written as the turns complete.
+	// Times in it are counted from origin.
+	timeline     *bufio.Writer
+	timelineFile *os.File
+	events       int
+	origin       time.Time
+}
+
+// startTiming starts recording the timings of the workers. It returns nil if neither the report nor the timeline was asked for.
+func startTiming(p golParams) *timingRecorder {
+	if !p.timing && p.timeline == "" {
+		return nil
+	}
+	t := &timingRecorder{
+		p:        p,
+		compute:  make([]time.Duration, p.threads),
+		halo:     make([]time.Duration, p.threads),
+		wait:     make([]time.Duration, p.threads),
+		rows:     make([]int, p.threads),
+		rowTurns: make([]int, p.threads),
+		origin:   time.Now(),
+	}
+	if p.timeline != "" {
+		_ = os.Mkdir("out", os.ModePerm)
+		var ioError error
+		t.timelineFile, ioError = os.Create(filepath.Join("out", p.timeline))
+		check(ioError)
+		t.timeline = bufio.NewWriter(t.timelineFile)
+		_, _ = t.timeline.WriteString(`{"displayTimeUnit":"ms","traceEvents":[` + "\n")
+		t.event(traceEvent{Name: "process_name", Ph: "M", Args: map[string]string{
+			"name": "gameoflife " + strconv.Itoa(p.imageWidth) + "x" + strconv.Itoa(p.imageHeight)}})
+		t.event(traceEvent{Name: "thread_name", Ph: "M", Args: map[string]string{"name": "distributor"}})
+		for i := 0; i < p.threads; i++ {
+			t.event(traceEvent{Name: "thread_name", Ph: "M", Tid: i + 1, Args: map[string]string{"name": "worker " + strconv.Itoa(i)}})
+		}
+	}
+	return t
+}
+
+// traceEvent is an event in the Chrome trace event format, which chrome://tracing and Perfetto show as a timeline.
+// Complete events, with Ph "X", are spans of time, and metadata events, with Ph "M", name the process and threads.
+// Times are in microseconds.
+type traceEvent struct {
+	Name string            `json:"name"`
+	Cat  string            `json:"cat,omitempty"`
+	Ph   string            `json:"ph"`
+	Ts   float64           `json:"ts"`
+	Dur  float64           `json:"dur,omitempty"`
+	Pid  int               `json:"pid"`
+	Tid  int               `json:"tid"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// event adds an event to the timeline, on a line of its own.
+func (t *timingRecorder) event(e traceEvent) {
+	data, err := json.Marshal(e)
+	check(err)
+	if t.events > 0 {
+		_, _ = t.timeline.WriteString(",\n")
+	}
+	_, _ = t.timeline.Write(data)
+	t.events++
+}
+
+// span adds a complete event from start lasting d to the timeline.
+func (t *timingRecorder) span(name, cat string, tid int, start time.Time, d time.Duration, args map[string]string) {
+	t.event(traceEvent{
+		Name: name,
+		Cat:  cat,
+		Ph:   "X",
+		Ts:   float64(start.Sub(t.origin).Nanoseconds()) / 1000,
+		Dur:  float64(d.Nanoseconds()) / 1000,
+		Tid:  tid,
+		Args: args,
+	})
+}
+
+// turnComplete adds up the timings of every worker in a turn, which started when the distributor sent it
+// and finished when the last report came back.
+func (t *timingRecorder) turnComplete(turns int, started, finished time.Time, timings []workerTiming) {
+	if t == nil {
+		return
+	}
+	var slowest, total time.Duration
+	for i, timing := range timings {
+		t.compute[i] += timing.compute
+		t.halo[i] += timing.halo
+		t.wait[i] += timing.wait
+		t.rows[i] = timing.rows
+		t.rowTurns[i] += timing.rows
+		total += timing.compute
+		if timing.compute > slowest {
+			slowest = timing.compute
+		}
+	}
+	t.imbalance.turns++
+	t.slowestSum += slowest
+	t.totalCompute += total
+	if total > 0 {
+		turnImbalance := float64(slowest)*float64(len(timings))/float64(total) - 1
+		t.imbalanceSum += turnImbalance
+		if turnImbalance > t.imbalance.worst {
+			t.imbalance.worst, t.imbalance.worstTurn = turnImbalance, turns
+		}
+	}
+
+	if t.timeline == nil {
+		return
+	}
+	t.span("turn "+strconv.Itoa(turns), "distributor", 0, started, finished.Sub(started), nil)
+	for i, timing := range timings {
+		args := map[string]string{"turn": strconv.Itoa(turns), "rows": strconv.Itoa(timing.rows)}
+		t.span("channel wait", "wait", i+1, timing.start.Add(-timing.wait), timing.wait, args)
+		t.span("compute", "compute", i+1, timing.start, timing.compute, args)
+		t.span("halo wait", "wait", i+1, timing.start.Add(timing.compute), timing.halo, args)
+	}
+}
+
+// imbalanceSoFar sums up how unevenly the turns so far were shared out.
+func (t *timingRecorder) imbalanceSoFar() imbalance {
+	result := t.imbalance
+	if result.turns > 0 {
+		result.mean = t.imbalanceSum / float64(result.turns)
+	}
+	if t.slowestSum > 0 {
+		result.idle = 1 - float64(t.totalCompute)/(float64(t.slowestSum)*float64(t.p.threads))
+	}
+	return result
+}
+
+// finish prints the time each worker spent on each part of its turns and how unevenly they were shared,
+// if that was asked for, and closes the timeline.
+func (t *timingRecorder) finish() {
+	if t == nil {
+		return
+	}
+	if t.timeline != nil {
+		_, _ = t.timeline.WriteString("\n]}\n")
+		check(t.timeline.Flush())
+		check(t.timelineFile.Close())
+		fmt.Println("File", t.timelineFile.Name(), "output done!")
+	}
+	if !t.p.timing {
+		return
+	}
+
+	result := t.imbalanceSoFar()
+	fmt.Println("Worker timing over", result.turns, "turns")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "Worker\tRows\tCompute\tHalo wait\tChannel wait\tCompute per row\t")
+	for i := range t.compute {
+		perRow := time.Duration(0)
+		if t.rowTurns[i] > 0 {
+			perRow = t.compute[i] / time.Duration(t.rowTurns[i])
+		}
+		fmt.Fprintf(w, "%d\t%d\t%v\t%v\t%v\t%v\t\n", i, t.rows[i], t.compute[i].Round(time.Microsecond),
+			t.halo[i].Round(time.Microsecond), t.wait[i].Round(time.Microsecond), perRow.Round(time.Microsecond))
+	}
+	_ = w.Flush()
+	fmt.Printf("On average the slowest worker of a turn computed for %.1f%% longer than the average worker, and at worst %.1f%% longer, in turn %d.\n",
+		100*result.mean, 100*result.worst, result.worstTurn)
+	fmt.Printf("Waiting for the slowest worker each turn left the workers idle for %.1f%% of the time.\n", 100*result.idle)
+}
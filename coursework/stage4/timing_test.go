@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImbalance(t *testing.T) {
+	recorder := startTiming(golParams{threads: 2, timing: true})
+	start := time.Now()
+	recorder.turnComplete(1, start, start, []workerTiming{{compute: 30 * time.Millisecond, rows: 3}, {compute: 10 * time.Millisecond, rows: 1}})
+	recorder.turnComplete(2, start, start, []workerTiming{{compute: 10 * time.Millisecond, rows: 3}, {compute: 10 * time.Millisecond, rows: 1}})
+
+	result := recorder.imbalanceSoFar()
+	assert.Equal(t, 2, result.turns)
+	assert.InDelta(t, 0.25, result.mean, 1e-9, "the slowest worker took 50% longer than the average in turn 1, and no longer in turn 2")
+	assert.InDelta(t, 0.5, result.worst, 1e-9)
+	assert.Equal(t, 1, result.worstTurn)
+	assert.InDelta(t, 0.25, result.idle, 1e-9, "20ms of the 80ms the workers were given was spent waiting")
+	assert.Equal(t, []time.Duration{40 * time.Millisecond, 20 * time.Millisecond}, recorder.compute)
+}
+
+func TestTiming(t *testing.T) {
+	// 6 threads do not divide 64 evenly, so 4 workers get 11 rows and 2 get 10.
+	p := golParams{
+		turns:       30,
+		threads:     6,
+		imageWidth:  64,
+		imageHeight: 64,
+		timing:      true,
+		timeline:    "test_timeline.json",
+	}
+	cleanOutput(t)
+
+	out := captureStdout(func() { gameOfLife(p, nil, nil) })
+	assert.Contains(t, out, "Worker timing over 30 turns")
+	assert.Regexp(t, `(?m)^3 +11 `, out)
+	assert.Regexp(t, `(?m)^5 +10 `, out)
+	assert.Contains(t, out, "left the workers idle for")
+
+	data, err := ioutil.ReadFile("out/test_timeline.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var timeline struct {
+		TraceEvents []traceEvent
+	}
+	if !assert.NoError(t, json.Unmarshal(data, &timeline)) {
+		return
+	}
+	// The process and the distributor and every worker are named, then each turn has a span for the distributor
+	// and three for every worker.
+	events := timeline.TraceEvents
+	assert.Len(t, events, 2+p.threads+p.turns*(1+3*p.threads))
+
+	names := make(map[int]string)
+	lastEnd := make(map[int]float64)
+	for _, e := range events {
+		switch e.Ph {
+		case "M":
+			if e.Name == "thread_name" {
+				names[e.Tid] = e.Args["name"]
+			}
+		case "X":
+			assert.True(t, e.Dur >= 0, "%+v", e)
+			assert.True(t, e.Ts >= 0, "%+v starts before the timeline", e)
+			// Each worker does one thing at a time, so its spans follow one another.
+			if e.Tid > 0 {
+				assert.True(t, e.Ts >= lastEnd[e.Tid]-1, "%+v overlaps the span before it", e)
+				lastEnd[e.Tid] = e.Ts + e.Dur
+			}
+		}
+	}
+	assert.Equal(t, "distributor", names[0])
+	assert.Equal(t, "worker 5", names[6])
+}
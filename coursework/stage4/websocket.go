@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the client's key to make the Sec-WebSocket-Accept header (RFC 6455).
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize stops a client from making the server allocate without limit.
+const maxMessageSize = 1 << 16
+
+// WebSocket frame opcodes.
+const (
+	wsContinuation byte = 0x0
+	wsText         byte = 0x1
+	wsBinary       byte = 0x2
+	wsClose        byte = 0x8
+	wsPing         byte = 0x9
+	wsPong         byte = 0xA
+)
+
+// wsConn is one end of a WebSocket connection.
+// Frames sent by a client must be masked, frames sent by a server must not.
+type wsConn struct {
+	conn   net.Conn
+	rw     *bufio.ReadWriter
+	mask   bool
+	writes sync.Mutex
+}
+
+// websocketAccept returns the Sec-WebSocket-Accept header for the client's Sec-WebSocket-Key.
+func websocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerContains reports whether a comma separated header has the given token, ignoring case.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the opening handshake and takes over the connection from the http server.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "Expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets are not supported", http.StatusInternalServerError)
+		return nil, errors.New("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// writeFrame sends a whole message in a single frame.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writes.Lock()
+	defer c.writes.Unlock()
+
+	header := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = 127
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	if c.mask {
+		header[1] |= 0x80
+		var key [4]byte
+		binary.BigEndian.PutUint32(key[:], maskKey())
+		header = append(header, key[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ key[i%4]
+		}
+		payload = masked
+	}
+
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// maskKeys makes the masking keys for frames sent by a client.
+var maskKeys = struct {
+	sync.Mutex
+	next uint32
+}{next: 0x9E3779B9}
+
+func maskKey() uint32 {
+	maskKeys.Lock()
+	defer maskKeys.Unlock()
+	// xorshift is plenty: masking only stops proxies from mistaking frames for other protocols.
+	maskKeys.next ^= maskKeys.next << 13
+	maskKeys.next ^= maskKeys.next >> 17
+	maskKeys.next ^= maskKeys.next << 5
+	return maskKeys.next
+}
+
+// readFrame reads one frame, unmasking its payload if necessary.
+// A frame masked the wrong way for this end is an error, after which the connection must be closed (RFC 6455 section 5.1).
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.rw, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	if masked == c.mask {
+		if c.mask {
+			err = errors.New("WebSocket frame from the server is masked")
+		} else {
+			err = errors.New("WebSocket frame from the client is not masked")
+		}
+		return
+	}
+
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.rw, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxMessageSize {
+		err = errors.New("WebSocket frame too large")
+		return
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.rw, key[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.rw, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return
+}
+
+// readMessage returns the next text or binary message, answering pings and joining fragments on the way.
+// It returns io.EOF once the other end has closed the connection.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsClose:
+			_ = c.writeFrame(wsClose, nil)
+			return nil, io.EOF
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsText, wsBinary, wsContinuation:
+			message = append(message, payload...)
+			if len(message) > maxMessageSize {
+				return nil, errors.New("WebSocket message too large")
+			}
+			if fin {
+				return message, nil
+			}
+		}
+	}
+}
+
+// close sends a close frame and closes the connection.
+func (c *wsConn) close() {
+	_ = c.writeFrame(wsClose, nil)
+	_ = c.conn.Close()
+}
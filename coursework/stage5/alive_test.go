@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"uk.ac.bris.cs/gameoflife/reference"
+)
+
+// captureStdout returns everything printed to stdout while f runs.
+func captureStdout(f func()) string {
+	r, w, err := os.Pipe()
+	check(err)
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		out <- string(data)
+	}()
+	f()
+	os.Stdout = stdout
+	_ = w.Close()
+	return <-out
+}
+
+// TestAliveCount reports the alive cells every millisecond, and checks every count against
+// the reference for the turn printed with it. Run it with -race to check that the count is
+// never taken while a turn is being calculated.
+func TestAliveCount(t *testing.T) {
+	p := golParams{
+		turns:         1000,
+		threads:       4,
+		imageWidth:    64,
+		imageHeight:   64,
+		aliveInterval: time.Millisecond,
+	}
+	world, err := reference.ReadPGM("images/64x64.pgm")
+	if !assert.NoError(t, err) {
+		return
+	}
+	// The run writes its final image to out/ in a directory of its own.
+	defer reference.InTempDir(t)()
+	check(reference.WritePGM("images/64x64.pgm", world))
+	counts := []int{reference.Alive(world)}
+	for turn := 0; turn < p.turns; turn++ {
+		world = reference.Step(world)
+		counts = append(counts, reference.Alive(world))
+	}
+
+	out := captureStdout(func() { gameOfLife(p, nil) })
+	reports := regexp.MustCompile(`(?m)^Turn (\d+) alive: (\d+)$`).FindAllStringSubmatch(out, -1)
+	assert.NotEmpty(t, reports)
+	for _, report := range reports {
+		turn, _ := strconv.Atoi(report[1])
+		n, _ := strconv.Atoi(report[2])
+		if assert.True(t, turn <= p.turns, "turn %d", turn) {
+			assert.Equal(t, counts[turn], n, "turn %d", turn)
+		}
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"time"
 )
 
+// defaultAliveInterval is how often the number of alive cells is reported, unless golParams says otherwise.
+const defaultAliveInterval = 2 * time.Second
+
 // distributor divides the work between workers and interacts with other goroutines.
 func distributor(p golParams, d distributorChans, alive chan []cell) {
 
@@ -35,28 +38,12 @@ func distributor(p golParams, d distributorChans, alive chan []cell) {
 	workerHeight := p.imageHeight / p.threads
 	bigWorkers := p.imageHeight % p.threads
 
-	pause := false
 	terminate := false
 
 	// 2b - Print alive cells every 2 seconds
-	ticker := time.NewTicker(2 * time.Second)
-	go func() {
-		for {
-			<-ticker.C
-			if pause == false {
-				totalAlive := 0
-				for y := 0; y < p.imageHeight; y++ {
-					for x := 0; x < p.imageWidth; x++ {
-						if world[y][x] == 0xFF {
-							totalAlive++
-						}
-					}
-				}
-				fmt.Println("alive:", totalAlive)
-			}
-		}
-
-	}()
+	// The ticker is read by the turn loop below, so the world is never counted while a turn is being calculated.
+	ticker := time.NewTicker(p.aliveInterval)
+	defer ticker.Stop()
 
 	// Calculate the new state of Game of Life after the given number of turns.
 Turns:
@@ -72,23 +59,37 @@ Turns:
 
 			case 'p':
 				fmt.Println("Paused")
-				pause = true
 				var resume rune
 				for resume != 'p' {
 					resume = <-d.key
 				}
-				pause = false
 				fmt.Println("Continuing")
 
 			case 'q':
 				fmt.Println("Terminate and generate PGM")
 				break Turns
 			}
+		case <-ticker.C:
+			totalAlive := 0
+			for y := 0; y < p.imageHeight; y++ {
+				for x := 0; x < p.imageWidth; x++ {
+					if world[y][x] == 0xFF {
+						totalAlive++
+					}
+				}
+			}
+			fmt.Println("Turn", turns, "alive:", totalAlive)
 		default:
 			//STAGE 5!!!
 
 			var wg sync.WaitGroup
 
+			// The workers write the next turn into a new world, so that every cell is calculated from the same turn.
+			next := make([][]uint8, p.imageHeight)
+			for y := range next {
+				next[y] = make([]uint8, p.imageWidth)
+			}
+
 			startY := 0
 			workerHeight++
 			for i := 0; i < p.threads; i++ {
@@ -131,13 +132,13 @@ Turns:
 							//Decide whether cell lives or dies
 							if world[y][x] == 0xFF {
 								if alive < 2 || alive > 3 {
-									world[y][x] = 0
+									next[y][x] = 0
 								} else {
-									world[y][x] = 0xFF
+									next[y][x] = 0xFF
 									numAlive++
 								}
 							} else if world[y][x] == 0 && alive == 3 {
-								world[y][x] = 0xFF
+								next[y][x] = 0xFF
 								numAlive++
 							}
 
@@ -148,6 +149,7 @@ Turns:
 				startY += workerHeight
 				wg.Wait()
 			}
+			world = next
 			turns++
 		}
 
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"time"
 )
 
 // golParams provides the details of how to run the Game of Life and which image to load.
@@ -11,6 +12,9 @@ type golParams struct {
 	threads     int
 	imageWidth  int
 	imageHeight int
+
+	// aliveInterval is how often the number of alive cells is reported. 0 means defaultAliveInterval.
+	aliveInterval time.Duration
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
@@ -82,6 +86,10 @@ type ioChans struct {
 // It places the created channels in the relevant structs.
 // It returns an array of alive cells returned by the distributor.
 func gameOfLife(p golParams, keyChan <-chan rune) []cell {
+	if p.aliveInterval == 0 {
+		p.aliveInterval = defaultAliveInterval
+	}
+
 	fmt.Println("----START", p.imageHeight, p.threads)
 
 	var dChans distributorChans